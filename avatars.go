@@ -0,0 +1,104 @@
+package main
+
+import (
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how long a speaker's avatar and accent colour are reused before Discord is asked again
+const speakerLookLifetime = 30 * time.Minute
+
+// looks stored between sweeps for expired ones
+const pruneLooksEvery = 500
+
+// speakerLook - what a full embed shows of its speaker
+type speakerLook struct {
+	avatarURL   string
+	accentColor int
+	expires     time.Time
+}
+
+// lookCache - speaker looks keyed by guild and user ID, so rendering quotes rarely calls the Discord API
+type lookCache struct {
+	mutex  sync.Mutex
+	looks  map[string]speakerLook
+	stores int
+}
+
+var speakerLooks = lookCache{looks: make(map[string]speakerLook)}
+
+func (cache *lookCache) get(key string, now time.Time) (speakerLook, bool) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	look, ok := cache.looks[key]
+	return look, ok && now.Before(look.expires)
+}
+
+func (cache *lookCache) put(key string, look speakerLook, now time.Time) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	cache.looks[key] = look
+	cache.stores++
+	if cache.stores < pruneLooksEvery {
+		return
+	}
+
+	cache.stores = 0
+	for key, look := range cache.looks {
+		if !now.Before(look.expires) {
+			delete(cache.looks, key)
+		}
+	}
+}
+
+// forget drops the looks of a user in every guild
+func (cache *lookCache) forget(discordID string) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	for key := range cache.looks {
+		if strings.HasSuffix(key, ":"+discordID) {
+			delete(cache.looks, key)
+		}
+	}
+}
+
+// lookupSpeaker - the avatar and accent colour of a quote's speaker, from the cache while it is fresh
+func lookupSpeaker(session *discordgo.Session, speaker data.User, guildID string) speakerLook {
+	key := guildID + ":" + speaker.DiscordID
+	now := time.Now()
+	if look, ok := speakerLooks.get(key, now); ok {
+		return look
+	}
+
+	look := fetchSpeakerLook(session, speaker, guildID)
+	look.expires = now.Add(speakerLookLifetime)
+	speakerLooks.put(key, look, now)
+	return look
+}
+
+// fetchSpeakerLook prefers the member in the state, which needs no request and has their guild avatar
+// only users fetched from the API have an accent colour, so members in the state get the embed's default one
+// when Discord can't be asked, the stored avatar hash or else the default avatar is used
+func fetchSpeakerLook(session *discordgo.Session, speaker data.User, guildID string) speakerLook {
+	member, err := session.State.Member(guildID, speaker.DiscordID)
+	if err == nil && member.User != nil {
+		guildMember := *member
+		guildMember.GuildID = guildID
+		return speakerLook{avatarURL: guildMember.AvatarURL(""), accentColor: member.User.AccentColor}
+	}
+
+	user, err := session.User(speaker.DiscordID)
+	if err != nil {
+		log.Printf("Failed to look up %s: %v", speaker.Name, err)
+		stored := discordgo.User{ID: speaker.DiscordID, Avatar: speaker.AvatarHash, Discriminator: "0"}
+		return speakerLook{avatarURL: stored.AvatarURL("")}
+	}
+	return speakerLook{avatarURL: user.AvatarURL(""), accentColor: user.AccentColor}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"sync"
+)
+
+// placements holds where the answer to an interaction goes when it was used outside the allowed channels,
+// keyed by interaction ID; interactions in allowed channels have no entry
+var placements sync.Map
+
+// placement - how the answer to an interaction outside the allowed channels is given
+type placement struct {
+	policy    data.OutsidePolicy
+	channelID string // the channel answers are redirected to
+}
+
+// channelRestricted is whether an interaction is one of the commands or buttons that post quotes
+// admin commands and other components answer wherever they are used
+func channelRestricted(icEvent *discordgo.InteractionCreate) bool {
+	if icEvent.Type == discordgo.InteractionMessageComponent {
+		action, _ := splitCustomID(icEvent.MessageComponentData().CustomID)
+		return action == shareQuoteComponent || action == confirmHeldComponent
+	} else if icEvent.Type != discordgo.InteractionApplicationCommand {
+		return false
+	}
+
+	name := icEvent.ApplicationCommandData().Name
+	return name == quoteSlashCommands.Name || name == quoteThisMessageCommand.Name
+}
+
+// placeInteraction records where the answer to an interaction goes if its channel isn't allowed
+// the returned func forgets the placement once the interaction has been handled
+func placeInteraction(manager data.Manager, icEvent *discordgo.InteractionCreate) func() {
+	if !channelRestricted(icEvent) {
+		return func() {}
+	}
+
+	guild := manager.FindGuildEntry(icEvent.GuildID)
+	rules := manager.FindChannelRules(guild)
+	if rules.Allows(icEvent.ChannelID) {
+		return func() {}
+	}
+
+	settings := manager.FindGuildSettings(guild)
+	place := placement{policy: settings.Outside(), channelID: settings.RedirectChannelID}
+	if len(place.channelID) == 0 && len(rules.Allowed()) > 0 {
+		place.channelID = rules.Allowed()[0]
+	}
+	if len(place.channelID) == 0 {
+		place.policy = data.EphemeralOutside
+	}
+
+	placements.Store(icEvent.ID, place)
+	return func() {
+		placements.Delete(icEvent.ID)
+	}
+}
+
+// respond answers an interaction, respecting where answers may go in its channel
+// every handler answers through it, so an interaction the router deferred gets its deferred response filled in
+func respond(session *discordgo.Session, interaction *discordgo.Interaction, response discordgo.InteractionResponse) {
+	value, outside := placements.Load(interaction.ID)
+	if outside && response.Type == discordgo.InteractionResponseChannelMessageWithSource &&
+		response.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		place := value.(placement)
+		if place.policy == data.RedirectOutside {
+			response = redirectResponse(session, place.channelID, response)
+		} else {
+			response.Data.Flags |= discordgo.MessageFlagsEphemeral
+		}
+	}
+
+	if deferred, ok := deferrals.Load(interaction.ID); ok {
+		finishDeferred(session, interaction, response, deferred.(deferral))
+		return
+	}
+
+	err := session.InteractionRespond(interaction, &response)
+	if err != nil {
+		log.Panicf("Unable to send response: %v", err)
+	}
+}
+
+// postInChannel posts a message for everyone in the channel of an interaction, respecting where answers may go in it
+// it returns false if nothing was posted, because the channel only gets private answers or sending failed
+func postInChannel(session *discordgo.Session, interaction *discordgo.Interaction, message *discordgo.MessageSend) bool {
+	channelID := interaction.ChannelID
+	if value, outside := placements.Load(interaction.ID); outside {
+		place := value.(placement)
+		if place.policy != data.RedirectOutside {
+			return false
+		}
+		channelID = place.channelID
+	}
+
+	_, err := session.ChannelMessageSendComplex(channelID, message)
+	if err != nil {
+		log.Printf("Failed to post in %s: %v", channelID, err)
+		return false
+	}
+	return true
+}
+
+// redirectResponse posts an answer in another channel and tells the member where it went
+func redirectResponse(session *discordgo.Session, channelID string, response discordgo.InteractionResponse) discordgo.InteractionResponse {
+	_, err := session.ChannelMessageSendComplex(channelID, responseToMessage(response, nil))
+	if err != nil {
+		log.Printf("Failed to redirect a response to %s: %v", channelID, err)
+		response.Data.Flags |= discordgo.MessageFlagsEphemeral
+		return response
+	}
+	return ephemeralResponse(fmt.Sprintf("Quotes go in <#%s>, so I've posted it there", channelID))
+}
+
+func adminChannelsHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, groupData *discordgo.ApplicationCommandInteractionDataOption) {
+	guild := manager.FindGuildEntry(interaction.GuildID)
+
+	optionData := groupData.Options[0]
+	optionMap := makeOptionMap(optionData.Options)
+
+	var response discordgo.InteractionResponse
+	switch optionData.Name {
+	case channelsAllow.Name:
+		channelID := optionMap["channel"].ChannelValue(nil).ID
+		manager.SetChannelRule(guild, channelID, true)
+		response = channelRulesResponse(manager.FindChannelRules(guild), manager.FindGuildSettings(guild))
+	case channelsDeny.Name:
+		channelID := optionMap["channel"].ChannelValue(nil).ID
+		manager.SetChannelRule(guild, channelID, false)
+		response = channelRulesResponse(manager.FindChannelRules(guild), manager.FindGuildSettings(guild))
+	case channelsRemove.Name:
+		channelID := optionMap["channel"].ChannelValue(nil).ID
+		if !manager.RemoveChannelRule(guild, channelID) {
+			response = ephemeralResponse(fmt.Sprintf("<#%s> isn't on the allowlist or the denylist", channelID))
+		} else {
+			response = channelRulesResponse(manager.FindChannelRules(guild), manager.FindGuildSettings(guild))
+		}
+	case channelsList.Name:
+		response = channelRulesResponse(manager.FindChannelRules(guild), manager.FindGuildSettings(guild))
+	case channelsOutside.Name:
+		settings := manager.FindGuildSettings(guild)
+		settings.OutsideChannels = data.OutsidePolicy(optionMap["answer"].StringValue())
+		if channelOption, ok := optionMap["channel"]; ok {
+			settings.RedirectChannelID = channelOption.ChannelValue(nil).ID
+		}
+		response = channelRulesResponse(manager.FindChannelRules(guild), manager.SaveGuildSettings(settings))
+	}
+
+	respond(session, interaction, response)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestChannelRestricted(t *testing.T) {
+	messageCommand := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionApplicationCommand,
+		Data: discordgo.ApplicationCommandInteractionData{Name: quoteThisMessageCommand.Name},
+	}}
+
+	tests := []struct {
+		name    string
+		icEvent *discordgo.InteractionCreate
+		want    bool
+	}{
+		{name: "quote command", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name), want: true},
+		{name: "message command", icEvent: messageCommand, want: true},
+		{name: "admin command", icEvent: commandEvent(quoteAdminSlashCommands.Name, adminSettings.Name), want: false},
+		{name: "share button", icEvent: componentEvent(makeCustomID(shareQuoteComponent, 7)), want: true},
+		{name: "confirming a held quote posts it", icEvent: componentEvent(makeCustomID(confirmHeldComponent, 7)), want: true},
+		{name: "cancelling a held quote", icEvent: componentEvent(makeCustomID(cancelHeldComponent, 7)), want: false},
+		{name: "approve button", icEvent: componentEvent(makeCustomID(approveQuoteComponent, 7)), want: false},
+	}
+
+	for _, test := range tests {
+		if got := channelRestricted(test.icEvent); got != test.want {
+			t.Errorf("%s: channelRestricted = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
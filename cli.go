@@ -0,0 +1,169 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/DeLucaJ/quotebot/internal/migration"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"os"
+)
+
+// runSubcommand handles command line subcommands, which run without connecting to Discord
+// it returns false when no subcommand was given and the bot should start normally
+func runSubcommand(botConfig BotConfig, args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	switch args[0] {
+	case "migration":
+		migrationSubcommand(botConfig, args[1:])
+	case "commands":
+		commandsSubcommand(botConfig, args[1:])
+	default:
+		log.Fatalf("Unknown subcommand %q", args[0])
+	}
+	return true
+}
+
+func migrationSubcommand(botConfig BotConfig, args []string) {
+	usage := "Usage: quotebot migration plan -guild <guild ID> [-out <file>] | quotebot migration list"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	switch args[0] {
+	case "plan":
+		migrationPlanSubcommand(botConfig, args[1:])
+	case "list":
+		migrationListSubcommand(botConfig)
+	default:
+		log.Fatal(usage)
+	}
+}
+
+func migrationPlanSubcommand(botConfig BotConfig, args []string) {
+	flags := flag.NewFlagSet("migration plan", flag.ExitOnError)
+	guildID := flags.String("guild", "", "Discord ID of the guild to plan the migration of")
+	output := flags.String("out", "migration-report.txt", "file the dry run report is written to")
+	_ = flags.Parse(args)
+
+	if len(*guildID) == 0 {
+		log.Fatal("Missing -guild: the Discord ID of the guild to plan the migration of")
+	}
+	if err := botConfig.require("connection-string"); err != nil {
+		log.Fatal(err)
+	}
+
+	// a dry run must not change anything, including the schema
+	manager := data.Open(botConfig.ConnectionString)
+	defer manager.Shutdown()
+
+	plan, err := migration.PlanMigration(manager, *guildID)
+	checkError(err, "Error planning migration: ")
+
+	reportFile, err := os.Create(*output)
+	checkError(err, "Error creating report file: ")
+	defer reportFile.Close()
+
+	err = plan.WriteReport(reportFile)
+	checkError(err, "Error writing report: ")
+
+	fmt.Printf("%d of %d legacy quotes would be imported, see %s\n", plan.Imported(), plan.Total, *output)
+}
+
+// migrationListSubcommand prints the status of every migration job, so imports can be followed without reading the logs
+func migrationListSubcommand(botConfig BotConfig) {
+	if err := botConfig.require("connection-string"); err != nil {
+		log.Fatal(err)
+	}
+
+	manager := data.Open(botConfig.ConnectionString)
+	defer manager.Shutdown()
+
+	jobs := manager.ListMigrationJobs()
+	if len(jobs) == 0 {
+		fmt.Println("No migration jobs")
+		return
+	}
+
+	for _, job := range jobs {
+		fmt.Printf("guild %s: %s, %d imported, %d duplicates, %d empty, %d unmatched, %d opted out, %d failed\n",
+			job.GuildDiscordID, job.Status, job.Imported, job.Duplicates, job.Empty, job.Unmatched, job.OptedOut, job.Failed)
+		if len(job.Error) > 0 {
+			fmt.Printf("  error: %s\n", job.Error)
+		}
+	}
+}
+
+// commandsSubcommand manages the registered application commands through the REST API, without the database
+func commandsSubcommand(botConfig BotConfig, args []string) {
+	usage := "Usage: quotebot commands sync|list|purge [-guild <guild ID>]"
+	if len(args) == 0 {
+		log.Fatal(usage)
+	}
+
+	flags := flag.NewFlagSet("commands "+args[0], flag.ExitOnError)
+	guildID := flags.String("guild", "", "Discord ID of a single guild to manage instead of the configured scope")
+	_ = flags.Parse(args[1:])
+
+	if err := botConfig.require("discord-token"); err != nil {
+		log.Fatal(err)
+	}
+
+	session, err := discordgo.New("Bot " + botConfig.DiscordToken)
+	checkError(err, "Error creating Discord Session: ")
+
+	// a bot user shares its ID with its application
+	botUser, err := session.User("@me")
+	checkError(err, "Error retrieving the bot user: ")
+
+	targets := commandTargets(botConfig, *guildID)
+
+	switch args[0] {
+	case "sync":
+		for _, target := range targets {
+			err = syncCommands(session, botUser.ID, target, allCommands)
+			checkError(err, fmt.Sprintf("Error syncing commands of %s: ", scopeName(target)))
+			fmt.Printf("Synced %d commands to %s\n", len(allCommands), scopeName(target))
+		}
+	case "list":
+		for _, target := range targets {
+			commands, err := session.ApplicationCommands(botUser.ID, target)
+			checkError(err, fmt.Sprintf("Error listing commands of %s: ", scopeName(target)))
+			fmt.Printf("%s: %d commands\n", scopeName(target), len(commands))
+			for _, command := range commands {
+				fmt.Printf("  %s (%s)\n", command.Name, command.ID)
+			}
+		}
+	case "purge":
+		for _, target := range targets {
+			err = syncCommands(session, botUser.ID, target, []*discordgo.ApplicationCommand{})
+			checkError(err, fmt.Sprintf("Error purging commands of %s: ", scopeName(target)))
+			fmt.Printf("Removed all commands from %s\n", scopeName(target))
+		}
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// commandTargets lists the guild IDs the commands subcommand works on, where an empty ID is the global scope
+func commandTargets(botConfig BotConfig, guildID string) []string {
+	if len(guildID) > 0 {
+		return []string{guildID}
+	} else if botConfig.CommandScope == globalCommandScope {
+		return []string{""}
+	} else if len(botConfig.CommandGuilds) == 0 {
+		log.Fatal("Missing -guild: the guild command scope has no command-guilds configured")
+	}
+	return botConfig.CommandGuilds
+}
+
+func scopeName(guildID string) string {
+	if len(guildID) == 0 {
+		return "global scope"
+	}
+	return "guild " + guildID
+}
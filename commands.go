@@ -1,10 +1,18 @@
 package main
 
 import (
+	"github.com/DeLucaJ/quotebot/internal/archive"
+	"github.com/DeLucaJ/quotebot/internal/data"
 	"github.com/bwmarrin/discordgo"
-	"log"
 )
 
+// privateOption lets members browse quotes without posting them in the channel
+var privateOption = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionBoolean,
+	Name:        "private",
+	Description: "only show the quotes to you, with a button to share them",
+}
+
 var quoteGet = discordgo.ApplicationCommandOption{
 	Type:        discordgo.ApplicationCommandOptionSubCommand,
 	Name:        "get",
@@ -15,6 +23,7 @@ var quoteGet = discordgo.ApplicationCommandOption{
 			Name:        "amount",
 			Description: "the number of quotes to get (max 10)",
 		},
+		&privateOption,
 	},
 }
 
@@ -47,6 +56,30 @@ var quoteBy = discordgo.ApplicationCommandOption{
 			Type:        discordgo.ApplicationCommandOptionUser,
 			Name:        "speaker",
 			Description: "the speaker of the quote",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "name",
+			Description: "any name or nickname the speaker has gone by, if they aren't picked",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "amount",
+			Description: "the number of quotes to send (max 10)",
+		},
+		&privateOption,
+	},
+}
+
+var quoteSearch = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "search",
+	Description: "sends random quotes containing some text",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "text",
+			Description: "the text the quotes contain",
 			Required:    true,
 		},
 		{
@@ -54,50 +87,495 @@ var quoteBy = discordgo.ApplicationCommandOption{
 			Name:        "amount",
 			Description: "the number of quotes to send (max 10)",
 		},
+		&privateOption,
+	},
+}
+
+var quotePrivacy = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "privacy",
+	Description: "choose whether others can quote you",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "opt-out",
+			Description: "refuse all new quotes about you",
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "require-consent",
+			Description: "hold new quotes about you until you accept them",
+		},
+	},
+}
+
+var myDataExport = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "export",
+	Description: "sends you a file of every quote you spoke or submitted",
+}
+
+var myDataErase = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "erase",
+	Description: "deletes quotes you spoke and anonymizes you everywhere",
+}
+
+var quoteMyData = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+	Name:        "mydata",
+	Description: "manage the personal data QuoteBot stores about you",
+	Options: []*discordgo.ApplicationCommandOption{
+		&myDataExport,
+		&myDataErase,
 	},
 }
 
 var quoteSlashCommands = discordgo.ApplicationCommand{
-	Type:        discordgo.ChatApplicationCommand,
-	Name:        "quote",
-	Description: "A command for managing and displaying quotes from QuoteBot",
+	Type:         discordgo.ChatApplicationCommand,
+	Name:         "quote",
+	Description:  "A command for managing and displaying quotes from QuoteBot",
+	DMPermission: &dmPermission,
 	Options: []*discordgo.ApplicationCommandOption{
 		&quoteGet,
 		&quoteAdd,
 		&quoteBy,
+		&quoteSearch,
+		&quotePrivacy,
+		&quoteMyData,
 	},
 }
 
 var quoteThisMessageCommand = discordgo.ApplicationCommand{
-	Type: discordgo.MessageApplicationCommand,
-	Name: "Quote This",
+	Type:         discordgo.MessageApplicationCommand,
+	Name:         "Quote This",
+	DMPermission: &dmPermission,
 }
 
-var allCommands = []*discordgo.ApplicationCommand{
-	&quoteSlashCommands,
-	&quoteThisMessageCommand,
+// members need Manage Server to see the admin commands unless a guild overrides it
+// roles granted capabilities with /quote-admin permissions still need the command enabled for them in the server's integration settings
+var adminDefaultPermissions int64 = discordgo.PermissionManageServer
+
+// every command works on the quotes of a server, so none are offered in DMs
+var dmPermission = false
+
+var adminApproval = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "approval",
+	Description: "require moderator approval for submitted quotes",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "enabled",
+			Description: "whether new quotes must be approved before they are shown",
+			Required:    true,
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "channel",
+			Description:  "the channel pending quotes are posted to",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+		},
+	},
+}
+
+// the smallest value of amount options
+var minAmountValue float64 = minAmount
+
+var settingsView = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "view",
+	Description: "shows every setting of this server",
+}
+
+var settingsMaxAmount = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "max-amount",
+	Description: "limit how many quotes a single command can send",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionInteger,
+			Name:        "amount",
+			Description: "the most quotes a command sends (max 10)",
+			Required:    true,
+			MinValue:    &minAmountValue,
+			MaxValue:    data.MaxAmountLimit,
+		},
+	},
+}
+
+var settingsAnnouncements = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "announcements",
+	Description: "choose whether and where QuoteBot announces itself when it starts",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "enabled",
+			Description: "whether QuoteBot announces itself",
+			Required:    true,
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "channel",
+			Description:  "the channel announcements are sent to, the system channel if not set",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+		},
+	},
+}
+
+var settingsEmbedStyle = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "embed-style",
+	Description: "choose how quotes look",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "style",
+			Description: "the look of quotes",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "full, with avatar, submitter and date", Value: string(data.FullEmbed)},
+				{Name: "compact, only the quote and speaker", Value: string(data.CompactEmbed)},
+			},
+		},
+	},
+}
+
+var settingsWhoCanAdd = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "who-can-add",
+	Description: "choose who may add quotes",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "who",
+			Description: "the members that may add quotes",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "everyone", Value: string(data.EveryoneMayAdd)},
+				{Name: "moderators and roles granted the add permission", Value: string(data.ModeratorsMayAdd)},
+			},
+		},
+	},
+}
+
+func rateLimitScopeChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(data.RateLimitScopes))
+	for index, scope := range data.RateLimitScopes {
+		choices[index] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  "per " + string(scope),
+			Value: string(scope),
+		}
+	}
+	return choices
+}
+
+var settingsRateLimit = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "rate-limit",
+	Description: "limit how often quote commands can be used",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "scope",
+			Description: "what the limit is counted per",
+			Required:    true,
+			Choices:     rateLimitScopeChoices(),
+		},
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "limit",
+			Description: `quotes per time like "5/30s", "off", or "default" for QuoteBot's own limit`,
+			Required:    true,
+		},
+	},
 }
 
-func registerAllCommands(session *discordgo.Session, guildID string) []string {
-	log.Println("Registering commands...")
-	registeredCommandIDs := make([]string, len(allCommands))
-	for index, command := range allCommands {
-		registeredCommand, err := session.ApplicationCommandCreate(session.State.User.ID, guildID, command)
-		if err != nil {
-			log.Panicf("Cannot create '%v' command: %v\n", command.Name, err)
+var settingsLegacyCommands = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "legacy-commands",
+	Description: "answer the original QuoteBot's !quote commands",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "enabled",
+			Description: "whether !quote, !quote add and !quote by are answered",
+			Required:    true,
+		},
+	},
+}
+
+var settingsNameDisplay = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "name-display",
+	Description: "choose which name quotes are shown with",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "style",
+			Description: "the name shown for speakers and submitters",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "server nickname", Value: string(data.NicknameDisplay)},
+				{Name: "display name", Value: string(data.GlobalNameDisplay)},
+				{Name: "username", Value: string(data.UsernameDisplay)},
+			},
+		},
+	},
+}
+
+var adminSettings = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+	Name:        "settings",
+	Description: "configure how QuoteBot behaves in this server",
+	Options: []*discordgo.ApplicationCommandOption{
+		&settingsView,
+		&settingsMaxAmount,
+		&settingsAnnouncements,
+		&settingsEmbedStyle,
+		&adminApproval,
+		&settingsWhoCanAdd,
+		&settingsRateLimit,
+		&settingsLegacyCommands,
+		&settingsNameDisplay,
+	},
+}
+
+func capabilityChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(data.Capabilities))
+	for index, capability := range data.Capabilities {
+		choices[index] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  string(capability),
+			Value: string(capability),
 		}
-		registeredCommandIDs[index] = registeredCommand.ID
 	}
-	return registeredCommandIDs
+	return choices
+}
+
+// roleCapabilityOptions are the options of the commands that grant or revoke a capability
+var roleCapabilityOptions = []*discordgo.ApplicationCommandOption{
+	{
+		Type:        discordgo.ApplicationCommandOptionRole,
+		Name:        "role",
+		Description: "the role whose members are affected",
+		Required:    true,
+	},
+	{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "capability",
+		Description: "what members with the role may do",
+		Required:    true,
+		Choices:     capabilityChoices(),
+	},
+}
+
+var permissionsGrant = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "grant",
+	Description: "allow members with a role to do something",
+	Options:     roleCapabilityOptions,
 }
 
-// Need to be able to cache registered command IDs by server
-func removeAllCommands(session *discordgo.Session, guildID string, registeredCommandIDs []string) {
-	log.Println("Removing commands...")
-	for _, commandID := range registeredCommandIDs {
-		err := session.ApplicationCommandDelete(session.State.User.ID, guildID, commandID)
-		if err != nil {
-			log.Panicf("Cannot delete '%v' command: %v", commandID, err)
+var permissionsRevoke = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "revoke",
+	Description: "stop allowing members with a role to do something",
+	Options:     roleCapabilityOptions,
+}
+
+var permissionsList = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "list",
+	Description: "shows what each role has been allowed to do",
+}
+
+var adminRolePermissions = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+	Name:        "permissions",
+	Description: "allow roles to add, edit, delete or export quotes and to configure QuoteBot",
+	Options: []*discordgo.ApplicationCommandOption{
+		&permissionsGrant,
+		&permissionsRevoke,
+		&permissionsList,
+	},
+}
+
+// channelOption is the option of the commands that change the lists of a single channel
+var channelOption = []*discordgo.ApplicationCommandOption{
+	{
+		Type:         discordgo.ApplicationCommandOptionChannel,
+		Name:         "channel",
+		Description:  "the channel to change",
+		Required:     true,
+		ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+	},
+}
+
+var channelsAllow = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "allow",
+	Description: "allow quotes in a channel; once any channel is allowed, quotes only go in allowed channels",
+	Options:     channelOption,
+}
+
+var channelsDeny = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "deny",
+	Description: "keep quotes out of a channel",
+	Options:     channelOption,
+}
+
+var channelsRemove = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "remove",
+	Description: "take a channel off the allowlist or the denylist",
+	Options:     channelOption,
+}
+
+var channelsList = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "list",
+	Description: "shows the channels quotes are allowed and denied in",
+}
+
+var channelsOutside = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "outside",
+	Description: "choose how quote commands are answered outside the allowed channels",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "answer",
+			Description: "how the answer is given",
+			Required:    true,
+			Choices: []*discordgo.ApplicationCommandOptionChoice{
+				{Name: "only show it to the member who asked", Value: string(data.EphemeralOutside)},
+				{Name: "post it in an allowed channel", Value: string(data.RedirectOutside)},
+			},
+		},
+		{
+			Type:         discordgo.ApplicationCommandOptionChannel,
+			Name:         "channel",
+			Description:  "the channel answers are posted in, the first allowed channel if not set",
+			ChannelTypes: []discordgo.ChannelType{discordgo.ChannelTypeGuildText},
+		},
+	},
+}
+
+var adminChannels = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommandGroup,
+	Name:        "channels",
+	Description: "choose the channels quotes can be posted in",
+	Options: []*discordgo.ApplicationCommandOption{
+		&channelsAllow,
+		&channelsDeny,
+		&channelsRemove,
+		&channelsList,
+		&channelsOutside,
+	},
+}
+
+var adminExport = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "export",
+	Description: "uploads a file of every quote in this server",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionString,
+			Name:        "format",
+			Description: "the file format of the export",
+			Required:    true,
+			Choices:     formatChoices(),
+		},
+	},
+}
+
+func formatChoices() []*discordgo.ApplicationCommandOptionChoice {
+	choices := make([]*discordgo.ApplicationCommandOptionChoice, len(archive.Formats))
+	for index, format := range archive.Formats {
+		choices[index] = &discordgo.ApplicationCommandOptionChoice{
+			Name:  string(format),
+			Value: string(format),
 		}
 	}
+	return choices
+}
+
+var adminImport = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "import",
+	Description: "adds the quotes of an exported or legacy QuoteBot file to this server",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionAttachment,
+			Name:        "file",
+			Description: "a JSON, CSV or Markdown file of quotes",
+			Required:    true,
+		},
+	},
+}
+
+var adminMigration = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "migration",
+	Description: "shows the status of this server's legacy quote migration",
+	Options: []*discordgo.ApplicationCommandOption{
+		{
+			Type:        discordgo.ApplicationCommandOptionBoolean,
+			Name:        "retry",
+			Description: "run a failed migration again",
+		},
+	},
 }
+
+var adminMapSpeakers = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "map-speakers",
+	Description: "match legacy quote speakers to members, then run the migration",
+}
+
+var adminSyncMembers = discordgo.ApplicationCommandOption{
+	Type:        discordgo.ApplicationCommandOptionSubCommand,
+	Name:        "sync-members",
+	Description: "refreshes the names of every member of this server",
+}
+
+var quoteAdminSlashCommands = discordgo.ApplicationCommand{
+	Type:                     discordgo.ChatApplicationCommand,
+	Name:                     "quote-admin",
+	Description:              "Commands for configuring QuoteBot in this server",
+	DefaultMemberPermissions: &adminDefaultPermissions,
+	DMPermission:             &dmPermission,
+	Options: []*discordgo.ApplicationCommandOption{
+		&adminSettings,
+		&adminRolePermissions,
+		&adminChannels,
+		&adminExport,
+		&adminImport,
+		&adminMigration,
+		&adminMapSpeakers,
+		&adminSyncMembers,
+	},
+}
+
+var allCommands = []*discordgo.ApplicationCommand{
+	&quoteSlashCommands,
+	&quoteThisMessageCommand,
+	&quoteAdminSlashCommands,
+}
+
+// custom ID prefixes of the message components the bot sends
+const (
+	approveQuoteComponent   = "approve-quote"
+	rejectQuoteComponent    = "reject-quote"
+	acceptConsentComponent  = "accept-consent"
+	declineConsentComponent = "decline-consent"
+	eraseDataComponent      = "erase-data"
+	mapSpeakerComponent     = "map-speaker"
+	mapPageComponent        = "map-page"
+	mapRunComponent         = "map-run"
+	confirmHeldComponent    = "confirm-held"
+	cancelHeldComponent     = "cancel-held"
+	shareQuoteComponent     = "share-quote"
+)
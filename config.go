@@ -0,0 +1,360 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/DeLucaJ/quotebot/internal/ratelimit"
+	"github.com/bwmarrin/discordgo"
+	"gopkg.in/yaml.v3"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config file read when no other one is given, for setups from before the config flag existed
+const defaultConfigFile string = "./config.json"
+
+// prefix of the environment variables that configure the bot
+const envPrefix = "QUOTEBOT_"
+
+// BotConfig internal struct for configuration management
+type BotConfig struct {
+	DiscordToken     string         // token the bot logs in with
+	ConnectionString string         // Postgres connection string of the database
+	GuildRetention   time.Duration  // how long the data of a guild that removed the bot is kept
+	CommandScope     string         // globalCommandScope registers commands once for every guild, guildCommandScope per guild
+	CommandGuilds    []string       // with the guild scope, the only guilds that get commands; every guild if empty
+	UserRateLimit    ratelimit.Rule // how often a member may use quote commands, unless their guild overrides it
+	ChannelRateLimit ratelimit.Rule // how often quote commands may be used in a channel, unless its guild overrides it
+	GuildRateLimit   ratelimit.Rule // how often quote commands may be used in a guild, unless it overrides it
+	MembersIntent    bool           // whether the privileged guild members intent is requested
+	ContentIntent    bool           // whether the privileged message content intent is requested
+}
+
+// where application commands are registered
+const (
+	globalCommandScope = "global"
+	guildCommandScope  = "guild"
+)
+
+// configSetting - a single setting, named the same in config files and flags
+type configSetting struct {
+	name         string
+	usage        string
+	defaultValue string
+	secret       bool // can also be read from a file named by the "-file" variant of the setting
+	apply        func(config *BotConfig, value string) error
+}
+
+var configSettings = []configSetting{
+	{
+		name:   "discord-token",
+		usage:  "token the bot logs in with",
+		secret: true,
+		apply: func(config *BotConfig, value string) error {
+			config.DiscordToken = value
+			return nil
+		},
+	},
+	{
+		name:   "connection-string",
+		usage:  "Postgres connection string of the database",
+		secret: true,
+		apply: func(config *BotConfig, value string) error {
+			config.ConnectionString = value
+			return nil
+		},
+	},
+	{
+		name:         "guild-retention",
+		usage:        `how long the data of a guild that removed the bot is kept, e.g. "720h"; "0s" purges it right away`,
+		defaultValue: defaultGuildRetention.String(),
+		apply: func(config *BotConfig, value string) error {
+			retention, err := time.ParseDuration(value)
+			if err != nil {
+				return fmt.Errorf("expected a duration such as \"720h\": %w", err)
+			} else if retention < 0 {
+				return errors.New("must not be negative")
+			}
+			config.GuildRetention = retention
+			return nil
+		},
+	},
+	{
+		name:         "command-scope",
+		usage:        `"global" registers commands once for every guild, "guild" registers them in each guild`,
+		defaultValue: globalCommandScope,
+		apply: func(config *BotConfig, value string) error {
+			if value != globalCommandScope && value != guildCommandScope {
+				return fmt.Errorf("expected %q or %q", globalCommandScope, guildCommandScope)
+			}
+			config.CommandScope = value
+			return nil
+		},
+	},
+	{
+		name:  "command-guilds",
+		usage: "comma separated IDs of the only guilds that get commands with the guild scope",
+		apply: func(config *BotConfig, value string) error {
+			config.CommandGuilds = nil
+			for _, guildID := range strings.Split(value, ",") {
+				if guildID = strings.TrimSpace(guildID); len(guildID) > 0 {
+					config.CommandGuilds = append(config.CommandGuilds, guildID)
+				}
+			}
+			return nil
+		},
+	},
+	rateLimitSetting("rate-limit-user", "how often a member may use quote commands", "5/30s",
+		func(config *BotConfig) *ratelimit.Rule { return &config.UserRateLimit }),
+	rateLimitSetting("rate-limit-channel", "how often quote commands may be used in a channel", "15/1m",
+		func(config *BotConfig) *ratelimit.Rule { return &config.ChannelRateLimit }),
+	rateLimitSetting("rate-limit-guild", "how often quote commands may be used in a guild", "60/1m",
+		func(config *BotConfig) *ratelimit.Rule { return &config.GuildRateLimit }),
+	boolSetting("members-intent", "request the privileged guild members intent, which member syncing and tracking need; "+
+		"it must be enabled for the bot in the developer portal first",
+		func(config *BotConfig) *bool { return &config.MembersIntent }),
+	boolSetting("message-content-intent", "request the privileged message content intent, which the legacy !quote commands need; "+
+		"it must be enabled for the bot in the developer portal first",
+		func(config *BotConfig) *bool { return &config.ContentIntent }),
+}
+
+// boolSetting - a setting that turns something on or off, off by default
+func boolSetting(name string, usage string, field func(config *BotConfig) *bool) configSetting {
+	return configSetting{
+		name:         name,
+		usage:        usage,
+		defaultValue: "false",
+		apply: func(config *BotConfig, value string) error {
+			enabled, err := strconv.ParseBool(value)
+			if err != nil {
+				return errors.New(`expected "true" or "false"`)
+			}
+			*field(config) = enabled
+			return nil
+		},
+	}
+}
+
+// intents - the privileged gateway intents the configuration asks for
+func (config BotConfig) intents() discordgo.Intent {
+	var intents discordgo.Intent
+	if config.MembersIntent {
+		intents |= discordgo.IntentGuildMembers
+	}
+	if config.ContentIntent {
+		intents |= discordgo.IntentMessageContent
+	}
+	return intents
+}
+
+// rateLimitSetting - a setting holding a token bucket rule
+func rateLimitSetting(name string, usage string, defaultValue string, field func(config *BotConfig) *ratelimit.Rule) configSetting {
+	return configSetting{
+		name:         name,
+		usage:        usage + `, as "<quotes>/<duration>" or "off"`,
+		defaultValue: defaultValue,
+		apply: func(config *BotConfig, value string) error {
+			rule, err := ratelimit.ParseRule(value)
+			*field(config) = rule
+			return err
+		},
+	}
+}
+
+// configLayer - the settings given by one source, by setting name
+type configLayer map[string]string
+
+// loadConfig - builds the configuration from defaults, a config file, QUOTEBOT_* environment variables and flags,
+// each overriding the one before; it also returns the arguments left after the flags
+func loadConfig(args []string) (BotConfig, []string, error) {
+	flags := flag.NewFlagSet("quotebot", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv(envPrefix+"CONFIG"),
+		"JSON, YAML or TOML config file, "+defaultConfigFile+" if it exists (env "+envPrefix+"CONFIG)")
+
+	flagLayer := make(configLayer)
+	for _, setting := range configSettings {
+		name := setting.name
+		flags.Func(name, fmt.Sprintf("%s (env %s)", setting.usage, envName(name)), func(value string) error {
+			flagLayer[name] = value
+			return nil
+		})
+		if setting.secret {
+			flags.Func(name+"-file", fmt.Sprintf("file holding the %s (env %s)", name, envName(name+"-file")), func(value string) error {
+				flagLayer[name+"-file"] = value
+				return nil
+			})
+		}
+	}
+	if err := flags.Parse(args); err != nil {
+		return BotConfig{}, nil, err
+	}
+
+	fileLayer, err := readConfigFile(*configFile)
+	if err != nil {
+		return BotConfig{}, nil, err
+	}
+
+	config, err := mergeConfig(defaultLayer(), fileLayer, envLayer(), flagLayer)
+	return config, flags.Args(), err
+}
+
+func defaultLayer() configLayer {
+	layer := make(configLayer)
+	for _, setting := range configSettings {
+		if len(setting.defaultValue) > 0 {
+			layer[setting.name] = setting.defaultValue
+		}
+	}
+	return layer
+}
+
+// envName is the environment variable of a setting, e.g. QUOTEBOT_DISCORD_TOKEN_FILE for discord-token-file
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func envLayer() configLayer {
+	layer := make(configLayer)
+	for _, setting := range configSettings {
+		names := []string{setting.name}
+		if setting.secret {
+			names = append(names, setting.name+"-file")
+		}
+		for _, name := range names {
+			if value, ok := os.LookupEnv(envName(name)); ok {
+				layer[name] = value
+			}
+		}
+	}
+	return layer
+}
+
+// readConfigFile reads the settings of a config file, picking the format by its extension
+// without a path, defaultConfigFile is read if it exists
+func readConfigFile(path string) (configLayer, error) {
+	if len(path) == 0 {
+		if _, err := os.Stat(defaultConfigFile); errors.Is(err, fs.ErrNotExist) {
+			return configLayer{}, nil
+		}
+		path = defaultConfigFile
+	}
+
+	fileData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	values := make(map[string]interface{})
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(fileData, &values)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(fileData, &values)
+	case ".toml":
+		err = toml.Unmarshal(fileData, &values)
+	default:
+		return nil, fmt.Errorf("config file %s: unknown format, expected .json, .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	known := make(map[string]bool)
+	for _, setting := range configSettings {
+		known[setting.name] = true
+		known[setting.name+"-file"] = setting.secret
+	}
+
+	layer := make(configLayer, len(values))
+	for name, value := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("config file %s: unknown setting %q", path, name)
+		}
+		layer[name] = configValue(value)
+	}
+	return layer, nil
+}
+
+// configValue turns a value decoded from a config file into the text form used by flags and the environment
+func configValue(value interface{}) string {
+	list, ok := value.([]interface{})
+	if !ok {
+		return fmt.Sprint(value)
+	}
+
+	items := make([]string, len(list))
+	for index, item := range list {
+		items[index] = fmt.Sprint(item)
+	}
+	return strings.Join(items, ",")
+}
+
+// mergeConfig applies the layers in order; within a layer a setting given directly wins over its file
+func mergeConfig(layers ...configLayer) (BotConfig, error) {
+	values := make(configLayer)
+	var problems []string
+
+	for _, layer := range layers {
+		for _, setting := range configSettings {
+			if path, ok := layer[setting.name+"-file"]; ok && setting.secret {
+				secret, err := os.ReadFile(path)
+				if err != nil {
+					problems = append(problems, fmt.Sprintf("%s-file: %v", setting.name, err))
+					continue
+				}
+				values[setting.name] = strings.TrimSpace(string(secret))
+			}
+			if value, ok := layer[setting.name]; ok {
+				values[setting.name] = value
+			}
+		}
+	}
+
+	var config BotConfig
+	for _, setting := range configSettings {
+		value, ok := values[setting.name]
+		if !ok {
+			continue
+		}
+		if err := setting.apply(&config, value); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", setting.name, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return config, fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return config, nil
+}
+
+// require - checks that the named settings have a value
+func (config BotConfig) require(names ...string) error {
+	var problems []string
+	for _, name := range names {
+		var missing bool
+		switch name {
+		case "discord-token":
+			missing = len(config.DiscordToken) == 0
+		case "connection-string":
+			missing = len(config.ConnectionString) == 0
+		}
+		if missing {
+			problems = append(problems, fmt.Sprintf("missing %s: set it in the config file, with -%s or %s, or put it in a file named by %s",
+				name, name, envName(name), envName(name+"-file")))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"github.com/DeLucaJ/quotebot/internal/ratelimit"
+	"github.com/bwmarrin/discordgo"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMergeConfig(t *testing.T) {
+	tokenFile := writeFile(t, "token", "  file-token\n")
+
+	tests := []struct {
+		name    string
+		layers  []configLayer
+		check   func(config BotConfig) bool
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			layers: []configLayer{defaultLayer()},
+			check: func(config BotConfig) bool {
+				return config.GuildRetention == defaultGuildRetention &&
+					config.CommandScope == globalCommandScope &&
+					config.UserRateLimit == ratelimit.Rule{Burst: 5, Per: 30 * time.Second} &&
+					!config.MembersIntent && !config.ContentIntent
+			},
+		},
+		{
+			name:   "later layers win",
+			layers: []configLayer{defaultLayer(), {"command-scope": "guild"}, {"command-scope": "global", "discord-token": "env"}, {"discord-token": "flag"}},
+			check: func(config BotConfig) bool {
+				return config.CommandScope == globalCommandScope && config.DiscordToken == "flag"
+			},
+		},
+		{
+			name:   "secret from a file",
+			layers: []configLayer{{"discord-token-file": tokenFile}},
+			check:  func(config BotConfig) bool { return config.DiscordToken == "file-token" },
+		},
+		{
+			name:   "a direct value wins over a file in the same layer",
+			layers: []configLayer{{"discord-token-file": tokenFile, "discord-token": "direct"}},
+			check:  func(config BotConfig) bool { return config.DiscordToken == "direct" },
+		},
+		{
+			name:   "a file in a later layer wins over an earlier direct value",
+			layers: []configLayer{{"discord-token": "direct"}, {"discord-token-file": tokenFile}},
+			check:  func(config BotConfig) bool { return config.DiscordToken == "file-token" },
+		},
+		{
+			name:   "files of settings that aren't secret are ignored",
+			layers: []configLayer{defaultLayer(), {"command-scope-file": tokenFile}},
+			check:  func(config BotConfig) bool { return config.CommandScope == globalCommandScope },
+		},
+		{
+			name:   "command guilds",
+			layers: []configLayer{{"command-guilds": " 1, 2,,3 "}},
+			check:  func(config BotConfig) bool { return reflect.DeepEqual(config.CommandGuilds, []string{"1", "2", "3"}) },
+		},
+		{
+			name:   "intents",
+			layers: []configLayer{defaultLayer(), {"members-intent": "true", "message-content-intent": "1"}},
+			check: func(config BotConfig) bool {
+				return config.intents() == discordgo.IntentGuildMembers|discordgo.IntentMessageContent
+			},
+		},
+		{
+			name:   "rate limit off",
+			layers: []configLayer{defaultLayer(), {"rate-limit-guild": "off"}},
+			check:  func(config BotConfig) bool { return !config.GuildRateLimit.Enabled() },
+		},
+		{
+			name:    "missing secret file",
+			layers:  []configLayer{{"connection-string-file": filepath.Join(t.TempDir(), "missing")}},
+			wantErr: "connection-string-file:",
+		},
+		{
+			name:    "invalid values",
+			layers:  []configLayer{{"guild-retention": "-1h", "command-scope": "everywhere", "rate-limit-user": "fast", "members-intent": "maybe"}},
+			wantErr: "command-scope: expected \"global\" or \"guild\"\n  guild-retention: must not be negative\n  members-intent: expected \"true\" or \"false\"\n  rate-limit-user: expected <tokens>/<duration>",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := mergeConfig(test.layers...)
+			if len(test.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("mergeConfig returned %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeConfig returned %v", err)
+			}
+			if !test.check(config) {
+				t.Errorf("mergeConfig = %+v", config)
+			}
+		})
+	}
+}
+
+func TestReadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		want    configLayer
+		wantErr string
+	}{
+		{
+			name:    "json",
+			file:    "config.json",
+			content: `{"discord-token": "token", "command-guilds": ["1", "2"], "members-intent": true}`,
+			want:    configLayer{"discord-token": "token", "command-guilds": "1,2", "members-intent": "true"},
+		},
+		{
+			name:    "yaml",
+			file:    "config.yml",
+			content: "connection-string-file: /run/secrets/dsn\nguild-retention: 24h\ncommand-guilds:\n  - 1\n  - 2\n",
+			want:    configLayer{"connection-string-file": "/run/secrets/dsn", "guild-retention": "24h", "command-guilds": "1,2"},
+		},
+		{
+			name:    "toml",
+			file:    "config.toml",
+			content: "command-scope = \"guild\"\nrate-limit-user = \"off\"\n",
+			want:    configLayer{"command-scope": "guild", "rate-limit-user": "off"},
+		},
+		{
+			name:    "unknown setting",
+			file:    "config.json",
+			content: `{"discord-tokn": "token"}`,
+			wantErr: `unknown setting "discord-tokn"`,
+		},
+		{
+			name:    "file variant of a setting that isn't secret",
+			file:    "config.json",
+			content: `{"command-scope-file": "scope"}`,
+			wantErr: `unknown setting "command-scope-file"`,
+		},
+		{
+			name:    "unknown format",
+			file:    "config.ini",
+			content: "discord-token = token",
+			wantErr: "unknown format",
+		},
+		{
+			name:    "malformed",
+			file:    "config.json",
+			content: `{"discord-token": `,
+			wantErr: "parsing config file",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			layer, err := readConfigFile(writeFile(t, test.file, test.content))
+			if len(test.wantErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("readConfigFile returned %v, want an error containing %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readConfigFile returned %v", err)
+			}
+			if !reflect.DeepEqual(layer, test.want) {
+				t.Errorf("readConfigFile = %v, want %v", layer, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	configFile := writeFile(t, "config.yaml", "discord-token: file\ncommand-scope: guild\nguild-retention: 1h\n")
+	t.Setenv(envPrefix+"CONFIG", configFile)
+	t.Setenv(envName("command-scope"), "global")
+	t.Setenv(envName("guild-retention"), "2h")
+
+	config, args, err := loadConfig([]string{"-guild-retention", "3h", "migration", "list"})
+	if err != nil {
+		t.Fatalf("loadConfig returned %v", err)
+	}
+
+	if config.DiscordToken != "file" || config.CommandScope != globalCommandScope || config.GuildRetention != 3*time.Hour {
+		t.Errorf("loadConfig = %+v, want the token from the file, the scope from the environment and the retention from the flag", config)
+	}
+	if !reflect.DeepEqual(args, []string{"migration", "list"}) {
+		t.Errorf("loadConfig left %v, want the subcommand", args)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	err := BotConfig{DiscordToken: "token"}.require("discord-token", "connection-string")
+	if err == nil || strings.Contains(err.Error(), "missing discord-token") || !strings.Contains(err.Error(), "missing connection-string") {
+		t.Errorf("require returned %v, want only the connection string missing", err)
+	}
+
+	if err := (BotConfig{DiscordToken: "token", ConnectionString: "dsn"}).require("discord-token", "connection-string"); err != nil {
+		t.Errorf("require returned %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/DeLucaJ/quotebot/internal/ratelimit"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"math"
+	"time"
+)
+
+// rateLimits - the token buckets quote commands draw from, with the bot's default rules
+type rateLimits struct {
+	limiter  *ratelimit.Limiter
+	defaults map[data.RateLimitScope]ratelimit.Rule
+}
+
+func newRateLimits(config BotConfig) rateLimits {
+	return rateLimits{
+		limiter: ratelimit.New(),
+		defaults: map[data.RateLimitScope]ratelimit.Rule{
+			data.UserRateLimit:    config.UserRateLimit,
+			data.ChannelRateLimit: config.ChannelRateLimit,
+			data.GuildRateLimit:   config.GuildRateLimit,
+		},
+	}
+}
+
+// rule is the rule of a scope in a guild, which is the bot's default unless the guild overrides it
+func (limits rateLimits) rule(settings data.GuildSettings, scope data.RateLimitScope) ratelimit.Rule {
+	override := settings.RateLimit(scope)
+	if len(override) == 0 {
+		return limits.defaults[scope]
+	}
+
+	rule, err := ratelimit.ParseRule(override)
+	if err != nil {
+		log.Printf("Ignoring invalid %s rate limit %q: %v", scope, override, err)
+		return limits.defaults[scope]
+	}
+	return rule
+}
+
+// take draws the cost of a command from the buckets of its member, channel and guild
+// it returns how long to wait when one of them is empty
+func (limits rateLimits) take(settings data.GuildSettings, guildID string, channelID string, userID string, cost int) (time.Duration, bool) {
+	return limits.limiter.Take(
+		ratelimit.Request{Key: "user:" + guildID + ":" + userID, Rule: limits.rule(settings, data.UserRateLimit), Cost: cost},
+		ratelimit.Request{Key: "channel:" + channelID, Rule: limits.rule(settings, data.ChannelRateLimit), Cost: cost},
+		ratelimit.Request{Key: "guild:" + guildID, Rule: limits.rule(settings, data.GuildRateLimit), Cost: cost},
+	)
+}
+
+// rateLimited is whether an interaction counts against the rate limits
+// only the commands and buttons members use to post quotes are limited
+func rateLimited(icEvent *discordgo.InteractionCreate) bool {
+	switch icEvent.Type {
+	case discordgo.InteractionMessageComponent:
+		action, _ := splitCustomID(icEvent.MessageComponentData().CustomID)
+		return action == shareQuoteComponent
+	case discordgo.InteractionApplicationCommand:
+		name := icEvent.ApplicationCommandData().Name
+		return name == quoteSlashCommands.Name || name == quoteThisMessageCommand.Name
+	}
+	return false
+}
+
+// interactionCost is how many tokens an interaction takes, each quote sent costs one
+// amounts are clamped to the guild's limit first, so asking for more than it serves costs no more
+func interactionCost(icEvent *discordgo.InteractionCreate, maxAmount int) int {
+	if icEvent.Type != discordgo.InteractionApplicationCommand {
+		return 1
+	}
+
+	commandData := icEvent.ApplicationCommandData()
+	if commandData.Name != quoteSlashCommands.Name || len(commandData.Options) == 0 {
+		return 1
+	}
+	if amountOption, ok := makeOptionMap(commandData.Options[0].Options)["amount"]; ok {
+		return clampAmount(int(amountOption.IntValue()), maxAmount)
+	}
+	return 1
+}
+
+// rateLimitInteraction checks an interaction against the rate limits, answering it if it has to wait
+func rateLimitInteraction(manager data.Manager, limits rateLimits, session *discordgo.Session, icEvent *discordgo.InteractionCreate) bool {
+	if !rateLimited(icEvent) || icEvent.Member == nil {
+		return true
+	}
+
+	settings := manager.FindGuildSettings(manager.FindGuildEntry(icEvent.GuildID))
+	cost := interactionCost(icEvent, settings.QuoteLimit())
+	wait, ok := limits.take(settings, icEvent.GuildID, icEvent.ChannelID, icEvent.Member.User.ID, cost)
+	if ok {
+		return true
+	}
+
+	response := slowDownResponse(wait)
+	respond(session, icEvent.Interaction, response)
+	return false
+}
+
+func slowDownResponse(wait time.Duration) discordgo.InteractionResponse {
+	return ephemeralResponse(fmt.Sprintf("Slow down! Try again in %ds", int(math.Ceil(wait.Seconds()))))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/DeLucaJ/quotebot/internal/ratelimit"
+	"github.com/bwmarrin/discordgo"
+	"testing"
+	"time"
+)
+
+// commandEvent builds a slash command interaction running a single subcommand
+func commandEvent(name string, subcommand string, options ...*discordgo.ApplicationCommandInteractionDataOption) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionApplicationCommand,
+		Data: discordgo.ApplicationCommandInteractionData{
+			Name: name,
+			Options: []*discordgo.ApplicationCommandInteractionDataOption{{
+				Type:    discordgo.ApplicationCommandOptionSubCommand,
+				Name:    subcommand,
+				Options: options,
+			}},
+		},
+	}}
+}
+
+func componentEvent(customID string) *discordgo.InteractionCreate {
+	return &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionMessageComponent,
+		Data: discordgo.MessageComponentInteractionData{CustomID: customID},
+	}}
+}
+
+func amountOption(amount int) *discordgo.ApplicationCommandInteractionDataOption {
+	return &discordgo.ApplicationCommandInteractionDataOption{
+		Type:  discordgo.ApplicationCommandOptionInteger,
+		Name:  "amount",
+		Value: float64(amount),
+	}
+}
+
+func TestInteractionCost(t *testing.T) {
+	tests := []struct {
+		name      string
+		icEvent   *discordgo.InteractionCreate
+		maxAmount int
+		want      int
+	}{
+		{name: "no amount", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name), maxAmount: 10, want: 1},
+		{name: "amount", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name, amountOption(4)), maxAmount: 10, want: 4},
+		{name: "amount above the limit", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name, amountOption(50)), maxAmount: 10, want: 10},
+		{name: "amount above a lower guild limit", icEvent: commandEvent(quoteSlashCommands.Name, quoteSearch.Name, amountOption(5)), maxAmount: 3, want: 3},
+		{name: "amount below the minimum", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name, amountOption(-5)), maxAmount: 10, want: minAmount},
+		{name: "other command", icEvent: commandEvent(quoteAdminSlashCommands.Name, "settings", amountOption(5)), maxAmount: 10, want: 1},
+		{name: "share button", icEvent: componentEvent(makeCustomID(shareQuoteComponent, 7)), maxAmount: 10, want: 1},
+	}
+
+	for _, test := range tests {
+		if got := interactionCost(test.icEvent, test.maxAmount); got != test.want {
+			t.Errorf("%s: interactionCost = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRateLimited(t *testing.T) {
+	messageCommand := &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+		Type: discordgo.InteractionApplicationCommand,
+		Data: discordgo.ApplicationCommandInteractionData{Name: quoteThisMessageCommand.Name},
+	}}
+
+	tests := []struct {
+		name    string
+		icEvent *discordgo.InteractionCreate
+		want    bool
+	}{
+		{name: "quote command", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name), want: true},
+		{name: "message command", icEvent: messageCommand, want: true},
+		{name: "admin command", icEvent: commandEvent(quoteAdminSlashCommands.Name, "settings"), want: false},
+		{name: "share button", icEvent: componentEvent(makeCustomID(shareQuoteComponent, 7)), want: true},
+		{name: "approve button", icEvent: componentEvent(makeCustomID(approveQuoteComponent, 7)), want: false},
+	}
+
+	for _, test := range tests {
+		if got := rateLimited(test.icEvent); got != test.want {
+			t.Errorf("%s: rateLimited = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestRateLimitRule(t *testing.T) {
+	defaultRule := ratelimit.Rule{Burst: 5, Per: 30 * time.Second}
+	limits := newRateLimits(BotConfig{UserRateLimit: defaultRule})
+
+	tests := []struct {
+		name     string
+		override string
+		want     ratelimit.Rule
+	}{
+		{name: "default", override: "", want: defaultRule},
+		{name: "override", override: "2/1m", want: ratelimit.Rule{Burst: 2, Per: time.Minute}},
+		{name: "override off", override: "off", want: ratelimit.Off},
+		{name: "invalid override", override: "often", want: defaultRule},
+	}
+
+	for _, test := range tests {
+		settings := data.GuildSettings{UserRateLimit: test.override}
+		if got := limits.rule(settings, data.UserRateLimit); got != test.want {
+			t.Errorf("%s: rule = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSlowDownResponse(t *testing.T) {
+	tests := []struct {
+		wait time.Duration
+		want string
+	}{
+		{wait: 10 * time.Second, want: "Slow down! Try again in 10s"},
+		{wait: 1500 * time.Millisecond, want: "Slow down! Try again in 2s"},
+		{wait: time.Millisecond, want: "Slow down! Try again in 1s"},
+	}
+
+	for _, test := range tests {
+		response := slowDownResponse(test.wait)
+		if response.Data.Content != test.want || response.Data.Flags&discordgo.MessageFlagsEphemeral == 0 {
+			t.Errorf("slowDownResponse(%v) = %q, want an ephemeral %q", test.wait, response.Data.Content, test.want)
+		}
+	}
+}
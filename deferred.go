@@ -0,0 +1,138 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"sync"
+)
+
+// deferrals holds the interactions acknowledged with a deferred response, keyed by interaction ID
+var deferrals sync.Map
+
+// deferral - how an interaction was acknowledged
+type deferral struct {
+	update    bool // the message of the component is updated later, rather than a new message being sent
+	ephemeral bool // the new message is only shown to the member
+}
+
+// deferInteraction acknowledges an interaction right away, so handlers that load quotes, render embeds or import files
+// aren't cut off by Discord's 3 second deadline; respond then fills in the deferred response
+// the returned func forgets the deferral once the interaction has been handled
+func deferInteraction(session *discordgo.Session, icEvent *discordgo.InteractionCreate) func() {
+	deferred, ok := deferralOf(icEvent)
+	if !ok {
+		return func() {}
+	}
+
+	response := discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+	}
+	if deferred.update {
+		response.Type = discordgo.InteractionResponseDeferredMessageUpdate
+	} else if deferred.ephemeral {
+		response.Data = &discordgo.InteractionResponseData{
+			Flags: discordgo.MessageFlagsEphemeral,
+		}
+	}
+
+	// respond answers directly when the interaction wasn't deferred, in case the failure was passing
+	err := session.InteractionRespond(icEvent.Interaction, &response)
+	if err != nil {
+		log.Printf("Unable to defer response: %v", err)
+		return func() {}
+	}
+
+	deferrals.Store(icEvent.ID, deferred)
+	return func() {
+		deferrals.Delete(icEvent.ID)
+	}
+}
+
+// deferralOf is how an interaction is deferred, if it is: commands and the share button are answered with a new message,
+// every other component by updating the message it is on
+func deferralOf(icEvent *discordgo.InteractionCreate) (deferral, bool) {
+	switch icEvent.Type {
+	case discordgo.InteractionApplicationCommand:
+		return deferral{ephemeral: answeredPrivately(icEvent)}, true
+	case discordgo.InteractionMessageComponent:
+		action, _ := splitCustomID(icEvent.MessageComponentData().CustomID)
+		return deferral{update: action != shareQuoteComponent}, true
+	}
+	return deferral{}, false
+}
+
+// answeredPrivately guesses whether the answer to an interaction will be ephemeral, which a deferred response has to settle up front
+// the guess is made without the database, so refusals and answers outside the allowed channels are sent as a followup by finishDeferred
+func answeredPrivately(icEvent *discordgo.InteractionCreate) bool {
+	if icEvent.Type != discordgo.InteractionApplicationCommand {
+		return false
+	}
+
+	commandData := icEvent.ApplicationCommandData()
+	switch commandData.Name {
+	case quoteAdminSlashCommands.Name:
+		return true
+	case quoteSlashCommands.Name:
+		subcommand := commandData.Options[0]
+		if subcommand.Name == quotePrivacy.Name || subcommand.Name == quoteMyData.Name {
+			return true
+		}
+		return privateRequested(makeOptionMap(subcommand.Options))
+	}
+	return false
+}
+
+// finishDeferred replaces a deferred response with the answer
+// an answer that doesn't match the visibility of the deferred response is sent as a followup, and the deferred response removed;
+// a deferred update that is answered with a new message keeps the component's message as it was and sends the answer as a followup
+func finishDeferred(session *discordgo.Session, interaction *discordgo.Interaction, response discordgo.InteractionResponse, deferred deferral) {
+	updating := response.Type == discordgo.InteractionResponseUpdateMessage
+	if deferred.update && !updating {
+		_, err := session.FollowupMessageCreate(interaction, true, responseToFollowup(response))
+		if err != nil {
+			log.Printf("Unable to send followup: %v", err)
+		}
+		return
+	}
+
+	ephemeral := response.Data.Flags&discordgo.MessageFlagsEphemeral != 0
+	if deferred.update || ephemeral == deferred.ephemeral {
+		_, err := session.InteractionResponseEdit(interaction, responseToEdit(response))
+		if err != nil {
+			log.Printf("Unable to edit response: %v", err)
+		}
+		return
+	}
+
+	_, err := session.FollowupMessageCreate(interaction, true, responseToFollowup(response))
+	if err != nil {
+		log.Printf("Unable to send followup: %v", err)
+		return
+	}
+
+	err = session.InteractionResponseDelete(interaction)
+	if err != nil {
+		log.Printf("Unable to delete deferred response: %v", err)
+	}
+}
+
+func responseToEdit(response discordgo.InteractionResponse) *discordgo.WebhookEdit {
+	return &discordgo.WebhookEdit{
+		Content:         &response.Data.Content,
+		Embeds:          &response.Data.Embeds,
+		Components:      &response.Data.Components,
+		Files:           response.Data.Files,
+		AllowedMentions: response.Data.AllowedMentions,
+	}
+}
+
+func responseToFollowup(response discordgo.InteractionResponse) *discordgo.WebhookParams {
+	return &discordgo.WebhookParams{
+		Content:         response.Data.Content,
+		Embeds:          response.Data.Embeds,
+		Components:      response.Data.Components,
+		Files:           response.Data.Files,
+		AllowedMentions: response.Data.AllowedMentions,
+		Flags:           response.Data.Flags & discordgo.MessageFlagsEphemeral,
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestDeferralOf(t *testing.T) {
+	private := &discordgo.ApplicationCommandInteractionDataOption{
+		Type:  discordgo.ApplicationCommandOptionBoolean,
+		Name:  privateOption.Name,
+		Value: true,
+	}
+
+	tests := []struct {
+		name    string
+		icEvent *discordgo.InteractionCreate
+		want    deferral
+		wantOK  bool
+	}{
+		{name: "quote command", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name), want: deferral{}, wantOK: true},
+		{name: "private quote command", icEvent: commandEvent(quoteSlashCommands.Name, quoteGet.Name, private), want: deferral{ephemeral: true}, wantOK: true},
+		{name: "my data", icEvent: commandEvent(quoteSlashCommands.Name, quoteMyData.Name), want: deferral{ephemeral: true}, wantOK: true},
+		{name: "admin command", icEvent: commandEvent(quoteAdminSlashCommands.Name, adminImport.Name), want: deferral{ephemeral: true}, wantOK: true},
+		{name: "share button", icEvent: componentEvent(makeCustomID(shareQuoteComponent, 7)), want: deferral{}, wantOK: true},
+		{name: "run migration button", icEvent: componentEvent(makeCustomID(mapRunComponent, "")), want: deferral{update: true}, wantOK: true},
+		{name: "approve button", icEvent: componentEvent(makeCustomID(approveQuoteComponent, 7)), want: deferral{update: true}, wantOK: true},
+		{name: "autocomplete", icEvent: &discordgo.InteractionCreate{Interaction: &discordgo.Interaction{Type: discordgo.InteractionApplicationCommandAutocomplete}}, wantOK: false},
+	}
+
+	for _, test := range tests {
+		got, ok := deferralOf(test.icEvent)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("%s: deferralOf = %+v, %v, want %+v, %v", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+}
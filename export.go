@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/archive"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"time"
+)
+
+func adminExportHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
+	optionMap := makeOptionMap(optionData.Options)
+
+	format := archive.JSON
+	if formatOption, ok := optionMap["format"]; ok {
+		format = archive.Format(formatOption.StringValue())
+	}
+
+	quotes := manager.ExportGuildQuotes(interaction.GuildID)
+
+	var response discordgo.InteractionResponse
+	file, err := archive.Encode(format, quotes)
+	if err != nil {
+		log.Printf("Error exporting quotes of %s: %v", interaction.GuildID, err)
+		response = ephemeralResponse("Sorry, something went wrong while exporting the quotes")
+	} else {
+		response = exportResponse(format, file, len(quotes))
+	}
+
+	respond(session, interaction, response)
+}
+
+func exportResponse(format archive.Format, file []byte, count int) discordgo.InteractionResponse {
+	fileName := fmt.Sprintf("quotes-%s.%s", time.Now().Format("2006-01-02"), format.Extension())
+
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("Exported %d quotes", count),
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Files: []*discordgo.File{
+				{
+					Name:        fileName,
+					ContentType: format.ContentType(),
+					Reader:      bytes.NewReader(file),
+				},
+			},
+		},
+	}
+}
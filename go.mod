@@ -3,7 +3,9 @@ module github.com/DeLucaJ/quotebot
 go 1.22
 
 require (
-	github.com/bwmarrin/discordgo v0.27.1
+	github.com/BurntSushi/toml v1.4.0
+	github.com/bwmarrin/discordgo v0.28.1
+	gopkg.in/yaml.v3 v3.0.1
 	gorm.io/driver/postgres v1.5.6
 	gorm.io/gorm v1.25.7
 )
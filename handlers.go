@@ -1,14 +1,15 @@
 package main
 
 import (
+	"fmt"
 	"github.com/DeLucaJ/quotebot/internal/data"
 	"github.com/DeLucaJ/quotebot/internal/migration"
 	"github.com/bwmarrin/discordgo"
 	"log"
+	"strconv"
 	"strings"
 )
 
-const maxAmount = 10
 const minAmount = 1
 
 func quoteSlashCommandHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
@@ -21,6 +22,12 @@ func quoteSlashCommandHandler(manager data.Manager, session *discordgo.Session,
 		quoteAddHandler(manager, session, icEvent.Interaction, options[0])
 	case quoteBy.Name:
 		quoteByHandler(manager, session, icEvent.Interaction, options[0])
+	case quoteSearch.Name:
+		quoteSearchHandler(manager, session, icEvent.Interaction, options[0])
+	case quotePrivacy.Name:
+		quotePrivacyHandler(manager, session, icEvent.Interaction, options[0])
+	case quoteMyData.Name:
+		quoteMyDataHandler(manager, session, icEvent.Interaction, options[0])
 	}
 }
 
@@ -30,40 +37,44 @@ func quoteGetHandler(manager data.Manager, session *discordgo.Session, interacti
 	amount := minAmount
 
 	if amountOption, ok := optionMap["amount"]; ok {
-		amount = clampAmount(int(amountOption.IntValue()))
+		settings := manager.FindGuildSettings(manager.FindGuildEntry(interaction.GuildID))
+		amount = clampAmount(int(amountOption.IntValue()), settings.QuoteLimit())
 	}
 	quotes := manager.GetNRandomQuotes(interaction.GuildID, amount)
 
-	response := getQuotesResponse(session, quotes)
+	response := getQuotesResponse(session, quotes, privateRequested(optionMap))
 
-	err := session.InteractionRespond(interaction, &response)
-	if err != nil {
-		log.Panicf("Unable to send response: %v", err)
-	}
+	respond(session, interaction, response)
 }
 
 func quoteByHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
 	optionMap := makeOptionMap(optionData.Options)
 
 	amount := minAmount
-	var speaker *discordgo.User
+	var speakerID string
 
 	if speakerOption, ok := optionMap["speaker"]; ok {
-		speaker = speakerOption.UserValue(session)
+		speakerID = speakerOption.UserValue(nil).ID
+	} else if nameOption, ok := optionMap["name"]; ok {
+		// names are matched against every name or nickname the speaker has gone by
+		guild := manager.FindGuildEntry(interaction.GuildID)
+		speakerID = manager.ResolveUser(strings.TrimSpace(nameOption.StringValue()), guild.ID).DiscordID
 	}
 
 	if amountOption, ok := optionMap["amount"]; ok {
-		amount = clampAmount(int(amountOption.IntValue()))
+		settings := manager.FindGuildSettings(manager.FindGuildEntry(interaction.GuildID))
+		amount = clampAmount(int(amountOption.IntValue()), settings.QuoteLimit())
 	}
 
-	quotes := manager.GetNRandomQuotesBySpeaker(speaker.ID, interaction.GuildID, amount)
-
-	response := getQuotesResponse(session, quotes)
-
-	err := session.InteractionRespond(interaction, &response)
-	if err != nil {
-		log.Panicf("Unable to send response: %v", err)
+	var response discordgo.InteractionResponse
+	if len(speakerID) == 0 {
+		response = emptyResponse("Sorry, I don't know who that is. Pick a speaker or type a name they've gone by")
+	} else {
+		quotes := manager.GetNRandomQuotesBySpeaker(speakerID, interaction.GuildID, amount)
+		response = getQuotesResponse(session, quotes, privateRequested(optionMap))
 	}
+
+	respond(session, interaction, response)
 }
 
 func quoteAddHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
@@ -82,13 +93,55 @@ func quoteAddHandler(manager data.Manager, session *discordgo.Session, interacti
 	}
 
 	quote := manager.AddQuote(content, speaker, submitter, interaction.GuildID)
+	dispatchNewQuote(manager, session, quote, interaction.GuildID)
 
-	response := addQuoteResponse(session, quote)
+	response := newQuoteResponse(manager, session, quote)
 
-	err := session.InteractionRespond(interaction, &response)
-	if err != nil {
-		log.Panicf("Unable to send response: %v", err)
+	respond(session, interaction, response)
+}
+
+func quoteSearchHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
+	optionMap := makeOptionMap(optionData.Options)
+
+	amount := minAmount
+	text := strings.TrimSpace(optionMap["text"].StringValue())
+
+	if amountOption, ok := optionMap["amount"]; ok {
+		settings := manager.FindGuildSettings(manager.FindGuildEntry(interaction.GuildID))
+		amount = clampAmount(int(amountOption.IntValue()), settings.QuoteLimit())
+	}
+
+	quotes := manager.SearchQuotes(interaction.GuildID, text, amount)
+	response := getQuotesResponse(session, quotes, privateRequested(optionMap))
+
+	respond(session, interaction, response)
+}
+
+// privateRequested is whether the member asked for quotes only they can see
+func privateRequested(optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) bool {
+	privateOption, ok := optionMap["private"]
+	return ok && privateOption.BoolValue()
+}
+
+func quotePrivacyHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
+	optionMap := makeOptionMap(optionData.Options)
+
+	guild := manager.FindGuildEntry(interaction.GuildID)
+	user := manager.AddUser(interaction.Member.User, guild)
+
+	if optOutOption, ok := optionMap["opt-out"]; ok {
+		user.OptOut = optOutOption.BoolValue()
 	}
+
+	if consentOption, ok := optionMap["require-consent"]; ok {
+		user.RequireConsent = consentOption.BoolValue()
+	}
+
+	manager.UpdateUserPrivacy(user)
+
+	response := privacySettingsResponse(user)
+
+	respond(session, interaction, response)
 }
 
 func makeOptionMap(options []*discordgo.ApplicationCommandInteractionDataOption) map[string]*discordgo.ApplicationCommandInteractionDataOption {
@@ -99,7 +152,7 @@ func makeOptionMap(options []*discordgo.ApplicationCommandInteractionDataOption)
 	return optionMap
 }
 
-func clampAmount(amount int) int {
+func clampAmount(amount int, maxAmount int) int {
 	if amount < minAmount {
 		return minAmount
 	} else if amount > maxAmount {
@@ -110,77 +163,348 @@ func clampAmount(amount int) int {
 }
 
 func quoteThisCommandHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
-	messageID := icEvent.ApplicationCommandData().TargetID
-	message, err := session.ChannelMessage(icEvent.ChannelID, messageID)
-	if err != nil {
-		log.Panicln("Failed to find message")
+	// the resolved message always has its content, fetching it needs the message content intent
+	commandData := icEvent.ApplicationCommandData()
+	message := commandData.Resolved.Messages[commandData.TargetID]
+	if message == nil {
+		var err error
+		message, err = session.ChannelMessage(icEvent.ChannelID, commandData.TargetID)
+		if err != nil {
+			log.Panicln("Failed to find message")
+		}
 	}
 
 	log.Println(message.Content)
 
 	quote := manager.AddQuote(message.Content, message.Author, icEvent.Interaction.Member.User, icEvent.Interaction.GuildID)
+	dispatchNewQuote(manager, session, quote, icEvent.Interaction.GuildID)
+
+	response := newQuoteResponse(manager, session, quote)
+
+	respond(session, icEvent.Interaction, response)
+}
+
+func quoteAdminSlashCommandHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	options := icEvent.ApplicationCommandData().Options
+
+	switch options[0].Name {
+	case adminSettings.Name:
+		adminSettingsHandler(manager, session, icEvent.Interaction, options[0])
+	case adminRolePermissions.Name:
+		adminPermissionsHandler(manager, session, icEvent.Interaction, options[0])
+	case adminChannels.Name:
+		adminChannelsHandler(manager, session, icEvent.Interaction, options[0])
+	case adminExport.Name:
+		adminExportHandler(manager, session, icEvent.Interaction, options[0])
+	case adminImport.Name:
+		adminImportHandler(manager, session, icEvent.Interaction, options[0])
+	case adminMigration.Name:
+		adminMigrationHandler(manager, session, icEvent.Interaction, options[0])
+	case adminMapSpeakers.Name:
+		adminMapSpeakersHandler(manager, session, icEvent.Interaction)
+	case adminSyncMembers.Name:
+		adminSyncMembersHandler(manager, session, icEvent.Interaction)
+	}
+}
+
+// hasIntent reports whether the session was opened with a gateway intent
+func hasIntent(session *discordgo.Session, intent discordgo.Intent) bool {
+	return session.Identify.Intents&intent == intent
+}
+
+// dispatchNewQuote forwards a freshly added quote to whoever has to sign off on it
+func dispatchNewQuote(manager data.Manager, session *discordgo.Session, quote data.Quote, guildID string) {
+	switch quote.Status {
+	case data.QuoteConsent:
+		requestSpeakerConsent(session, quote)
+	case data.QuotePending:
+		sendQuoteForApproval(manager, session, quote, guildID)
+	}
+}
+
+// dispatchNewQuotes dispatches each of a batch of imported quotes
+func dispatchNewQuotes(manager data.Manager, session *discordgo.Session, quotes []data.Quote, guildID string) {
+	for _, quote := range quotes {
+		dispatchNewQuote(manager, session, quote, guildID)
+	}
+}
+
+// newQuoteResponse answers a quote submission, showing the existing quote if the new one was held as a near-duplicate
+func newQuoteResponse(manager data.Manager, session *discordgo.Session, quote data.Quote) discordgo.InteractionResponse {
+	if quote.Status != data.QuoteHeld {
+		return addQuoteResponse(session, quote)
+	}
+	return heldQuoteResponse(session, quote, manager.FindSimilarQuote(quote))
+}
+
+// requestSpeakerConsent asks the speaker of a held quote whether it may be added
+func requestSpeakerConsent(session *discordgo.Session, quote data.Quote) {
+	channel, err := session.UserChannelCreate(quote.Speaker.DiscordID)
+	if err != nil {
+		log.Printf("Failed to open a DM with %s: %v", quote.Speaker.Name, err)
+		return
+	}
+
+	_, err = session.ChannelMessageSendComplex(channel.ID, consentRequestMessage(session, quote))
+	if err != nil {
+		log.Printf("Failed to ask %s for consent: %v", quote.Speaker.Name, err)
+	}
+}
+
+func quoteConsentComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	action, argument := splitCustomID(icEvent.MessageComponentData().CustomID)
+
+	quoteID, err := strconv.ParseUint(argument, 10, 64)
+	if err != nil {
+		log.Printf("Malformed consent component ID: %s", icEvent.MessageComponentData().CustomID)
+		return
+	}
+
+	// consent requests are sent in DMs, where the interaction has no member
+	user := icEvent.User
+	if icEvent.Member != nil {
+		user = icEvent.Member.User
+	}
+
+	var response discordgo.InteractionResponse
+	quote := manager.FindQuoteByID(uint(quoteID))
+	if quote.Speaker.DiscordID != user.ID {
+		response = ephemeralResponse("Sorry, only the speaker of this quote can answer")
+	} else if quote.Status != data.QuoteConsent {
+		response = ephemeralResponse("You have already answered for this quote")
+	} else {
+		var resolved bool
+		quote, resolved = manager.ResolveConsent(quote.ID, action == acceptConsentComponent)
+		if !resolved {
+			response = ephemeralResponse("You have already answered for this quote")
+		} else {
+			if quote.Status == data.QuotePending {
+				sendQuoteForApproval(manager, session, quote, quote.Guild.DiscordID)
+			}
+			response = consentDecisionResponse(session, quote)
+		}
+	}
+
+	respond(session, icEvent.Interaction, response)
+}
+
+func adminMigrationHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
+	optionMap := makeOptionMap(optionData.Options)
+
+	var job data.MigrationJob
+	if retryOption, ok := optionMap["retry"]; ok && retryOption.BoolValue() {
+		var waiting []data.Quote
+		job, waiting = migration.RetryMigration(manager, session, interaction.GuildID)
+		dispatchNewQuotes(manager, session, waiting, interaction.GuildID)
+	} else {
+		job = manager.FindMigrationJob(interaction.GuildID)
+	}
+
+	response := migrationStatusResponse(job)
+
+	respond(session, interaction, response)
+}
+
+// sendQuoteForApproval posts a pending quote to the guild's approval channel
+func sendQuoteForApproval(manager data.Manager, session *discordgo.Session, quote data.Quote, guildID string) {
+	guild := manager.FindGuildEntry(guildID)
+	settings := manager.FindGuildSettings(guild)
+
+	_, err := session.ChannelMessageSendComplex(settings.ApprovalChannelID, approvalRequestMessage(session, quote))
+	if err != nil {
+		log.Printf("Failed to post quote %d for approval in %s: %v", quote.ID, guild.Name, err)
+	}
+}
+
+func quoteApprovalComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	action, argument := splitCustomID(icEvent.MessageComponentData().CustomID)
+
+	var response discordgo.InteractionResponse
+	quoteID, err := strconv.ParseUint(argument, 10, 64)
+	if err != nil {
+		log.Printf("Malformed approval component ID: %s", icEvent.MessageComponentData().CustomID)
+		return
+	}
+
+	status := data.QuoteApproved
+	if action == rejectQuoteComponent {
+		status = data.QuoteRejected
+	}
+	// only a quote still waiting on the moderators can be decided, whatever button was pressed
+	quote, decided := manager.SetQuoteStatus(uint(quoteID), data.QuotePending, status)
+	if quote.ID != 0 && !decided {
+		response = ephemeralResponse(fmt.Sprintf("This quote has already been handled, it is %s", quote.Status))
+	} else {
+		response = approvalDecisionResponse(session, quote, icEvent.Member.User)
+	}
+
+	respond(session, icEvent.Interaction, response)
+}
+
+func heldQuoteComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	action, argument := splitCustomID(icEvent.MessageComponentData().CustomID)
+
+	quoteID, err := strconv.ParseUint(argument, 10, 64)
+	if err != nil {
+		log.Printf("Malformed held quote component ID: %s", icEvent.MessageComponentData().CustomID)
+		return
+	}
+
+	var response discordgo.InteractionResponse
+	var resolved bool
+	quote := manager.FindQuoteByID(uint(quoteID))
+	if quote.ID != 0 && quote.Submitter.DiscordID != icEvent.Member.User.ID {
+		response = ephemeralResponse("Sorry, only the one who submitted this quote can decide on it")
+	} else {
+		confirmed := action == confirmHeldComponent
+		quote, resolved = manager.ResolveHeldQuote(uint(quoteID), confirmed)
+		// a second click finds the quote already resolved, which must not send it on again
+		if confirmed && resolved {
+			dispatchNewQuote(manager, session, quote, icEvent.GuildID)
+		}
+		response = heldDecisionResponse(session, quote, confirmed)
+	}
+
+	respond(session, icEvent.Interaction, response)
+
+	// the held quote was only shown to the submitter, so an added quote is posted for everyone, where quotes may go
+	if resolved && quote.Status == data.QuoteApproved && icEvent.Message.Flags&discordgo.MessageFlagsEphemeral != 0 {
+		postInChannel(session, icEvent.Interaction, &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{quoteToEmbed(session, quote)}})
+	}
+}
 
-	response := addQuoteResponse(session, quote)
+// shareQuoteComponentHandler posts a quote someone found privately for the whole channel to see
+func shareQuoteComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	_, argument := splitCustomID(icEvent.MessageComponentData().CustomID)
 
-	err = session.InteractionRespond(icEvent.Interaction, &response)
+	quoteID, err := strconv.ParseUint(argument, 10, 64)
 	if err != nil {
-		log.Panicf("Unable to send response: %v", err)
+		log.Printf("Malformed share component ID: %s", icEvent.MessageComponentData().CustomID)
+		return
+	}
+
+	var response discordgo.InteractionResponse
+	quote := manager.FindQuoteByID(uint(quoteID))
+	if quote.ID == 0 || quote.Status != data.QuoteApproved || quote.Guild.DiscordID != icEvent.GuildID {
+		response = ephemeralResponse("Sorry, that quote no longer exists")
+	} else {
+		response = sharedQuoteResponse(session, quote, icEvent.Member.User)
+	}
+
+	respond(session, icEvent.Interaction, response)
+}
+
+// splitCustomID separates a component custom ID of the form "action:argument"
+func splitCustomID(customID string) (string, string) {
+	action, argument, _ := strings.Cut(customID, ":")
+	return action, argument
+}
+
+// makeCustomID builds a component custom ID understood by splitCustomID
+func makeCustomID(action string, argument interface{}) string {
+	return fmt.Sprintf("%s:%v", action, argument)
+}
+
+func hasPermission(member *discordgo.Member, permission int64) bool {
+	if member == nil {
+		return false
 	}
+	return member.Permissions&(permission|discordgo.PermissionAdministrator) != 0
 }
 
 var commandHandlers = map[string]func(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate){
 	quoteSlashCommands.Name:      quoteSlashCommandHandler,
 	quoteThisMessageCommand.Name: quoteThisCommandHandler,
+	quoteAdminSlashCommands.Name: quoteAdminSlashCommandHandler,
 }
 
-func interactionCreateHandler(manager data.Manager) func(*discordgo.Session, *discordgo.InteractionCreate) {
+// componentHandlers are keyed by the action part of a component's custom ID
+var componentHandlers = map[string]func(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate){
+	approveQuoteComponent:   quoteApprovalComponentHandler,
+	rejectQuoteComponent:    quoteApprovalComponentHandler,
+	eraseDataComponent:      eraseDataComponentHandler,
+	mapSpeakerComponent:     mapSpeakerComponentHandler,
+	mapPageComponent:        mapPageComponentHandler,
+	mapRunComponent:         mapRunComponentHandler,
+	acceptConsentComponent:  quoteConsentComponentHandler,
+	declineConsentComponent: quoteConsentComponentHandler,
+	confirmHeldComponent:    heldQuoteComponentHandler,
+	cancelHeldComponent:     heldQuoteComponentHandler,
+	shareQuoteComponent:     shareQuoteComponentHandler,
+}
+
+func interactionCreateHandler(manager data.Manager, limits rateLimits) func(*discordgo.Session, *discordgo.InteractionCreate) {
 	return func(session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
-		if handler, ok := commandHandlers[icEvent.ApplicationCommandData().Name]; ok {
-			handler(manager, session, icEvent)
+		// acknowledged before anything touches the database, so slow checks can't miss Discord's deadline
+		defer deferInteraction(session, icEvent)()
+
+		if !authorizeInteraction(manager, session, icEvent) || !rateLimitInteraction(manager, limits, session, icEvent) {
+			return
+		}
+		defer placeInteraction(manager, icEvent)()
+
+		switch icEvent.Type {
+		case discordgo.InteractionApplicationCommand:
+			if handler, ok := commandHandlers[icEvent.ApplicationCommandData().Name]; ok {
+				handler(manager, session, icEvent)
+			}
+		case discordgo.InteractionMessageComponent:
+			action, _ := splitCustomID(icEvent.MessageComponentData().CustomID)
+			if handler, ok := componentHandlers[action]; ok {
+				handler(manager, session, icEvent)
+			}
 		}
 	}
 }
 
-func ready(session *discordgo.Session, _ *discordgo.Ready) {
-	err := session.UpdateGameStatus(0, "/quote")
-	if err != nil {
-		log.Println("Error updated Bot Status")
+func readyHandler(config BotConfig) func(*discordgo.Session, *discordgo.Ready) {
+	return func(session *discordgo.Session, event *discordgo.Ready) {
+		err := session.UpdateGameStatus(0, "/quote")
+		if err != nil {
+			log.Println("Error updated Bot Status")
+		}
+
+		// global commands are not tied to a guild, so they are synced once per connection,
+		// and with the guild scope this clears the ones left from the global scope, which guilds would show twice
+		err = syncCommands(session, event.User.ID, "", globalCommands(config))
+		if err != nil {
+			log.Printf("Failed to register global commands: %v", err)
+		}
 	}
 }
 
-func guildCreateHandler(manager data.Manager, commandMap map[string][]string) func(*discordgo.Session, *discordgo.GuildCreate) {
+func guildCreateHandler(manager data.Manager, config BotConfig) func(*discordgo.Session, *discordgo.GuildCreate) {
 	return func(session *discordgo.Session, event *discordgo.GuildCreate) {
 		if event.Guild.Unavailable {
 			return
 		}
 
-		if !manager.GuildExists(event.Guild) {
-			manager.AddGuild(event.Guild)
-		}
-		guild := manager.FindGuild(event.Guild.ID)
+		manager.AddGuild(event.Guild)
+		guild := manager.FindGuildEntry(event.Guild.ID)
 
 		log.Println("Login: ", guild.Name)
 
-		members, err := session.GuildMembers(event.Guild.ID, "0", 1000)
-		if err != nil {
-			log.Printf("Failed to fetch members of %s: %v", event.Guild.Name, err)
-		}
-		for _, member := range members {
-			if manager.UserExists(member.User.ID, guild) {
-				continue
+		// listing members needs the members intent, without it only the members the event carries are stored
+		if hasIntent(session, discordgo.IntentGuildMembers) {
+			synced, err := syncGuildMembers(manager, session, guild, func(synced int) {
+				log.Printf("Syncing members of %s: %d/%d", guild.Name, synced, event.Guild.MemberCount)
+			})
+			if err != nil {
+				log.Printf("Failed to fetch members of %s after %d: %v", event.Guild.Name, synced, err)
 			}
-			manager.AddUser(member.User, guild)
+		} else {
+			manager.UpsertMembers(event.Guild.Members, guild)
 		}
 
-		commandMap[event.Guild.ID] = registerAllCommands(session, event.Guild.ID)
+		err := syncCommands(session, session.State.User.ID, event.Guild.ID, guildCommands(config, event.Guild.ID))
+		if err != nil {
+			log.Printf("Failed to register commands in %s: %v", event.Guild.Name, err)
+		}
 
-		migration.AttemptMigrateLegacyQuotes(manager, session, event)
+		dispatchNewQuotes(manager, session, migration.AttemptMigrateLegacyQuotes(manager, session, event), event.Guild.ID)
 
-		for _, channel := range event.Guild.Channels {
-			if channel.ID == event.Guild.ID {
-				_, _ = session.ChannelMessageSend(channel.ID, "QuoteBot is ready! Type /quote")
-				break
-			}
+		if channelID := manager.FindGuildSettings(guild).AnnouncementChannel(event.Guild); len(channelID) > 0 {
+			_, _ = session.ChannelMessageSend(channelID, "QuoteBot is ready! Type /quote")
 		}
 	}
 }
@@ -193,20 +517,16 @@ func guildUpdateHandler(manager data.Manager) func(*discordgo.Session, *discordg
 
 func memberAddHandler(manager data.Manager) func(*discordgo.Session, *discordgo.GuildMemberAdd) {
 	return func(session *discordgo.Session, add *discordgo.GuildMemberAdd) {
-		guild := manager.FindGuild(add.GuildID)
-
-		if manager.UserExists(add.User.ID, guild) {
-			return
-		}
+		guild := manager.FindGuildEntry(add.GuildID)
 
-		manager.AddUser(add.User, guild)
+		manager.UpsertMembers([]*discordgo.Member{add.Member}, guild)
 	}
 }
 
 func memberUpdateHandler(manager data.Manager) func(*discordgo.Session, *discordgo.GuildMemberUpdate) {
 	return func(session *discordgo.Session, update *discordgo.GuildMemberUpdate) {
-		guild := manager.FindGuild(update.GuildID)
+		guild := manager.FindGuildEntry(update.GuildID)
 
-		manager.UpdateGuildUser(update.User, guild)
+		manager.UpsertMembers([]*discordgo.Member{update.Member}, guild)
 	}
 }
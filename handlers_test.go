@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestCustomID(t *testing.T) {
+	tests := []struct {
+		customID     string
+		wantAction   string
+		wantArgument string
+	}{
+		{customID: makeCustomID(approveQuoteComponent, uint(42)), wantAction: approveQuoteComponent, wantArgument: "42"},
+		{customID: makeCustomID(mapSpeakerComponent, "old name"), wantAction: mapSpeakerComponent, wantArgument: "old name"},
+		{customID: makeCustomID(mapSpeakerComponent, "a:b"), wantAction: mapSpeakerComponent, wantArgument: "a:b"},
+		{customID: makeCustomID(mapRunComponent, ""), wantAction: mapRunComponent, wantArgument: ""},
+		{customID: "no-argument", wantAction: "no-argument", wantArgument: ""},
+	}
+
+	for _, test := range tests {
+		action, argument := splitCustomID(test.customID)
+		if action != test.wantAction || argument != test.wantArgument {
+			t.Errorf("splitCustomID(%q) = %q, %q, want %q, %q", test.customID, action, argument, test.wantAction, test.wantArgument)
+		}
+	}
+}
+
+func TestClampAmount(t *testing.T) {
+	tests := []struct {
+		amount    int
+		maxAmount int
+		want      int
+	}{
+		{amount: 3, maxAmount: 10, want: 3},
+		{amount: 10, maxAmount: 10, want: 10},
+		{amount: 11, maxAmount: 10, want: 10},
+		{amount: 0, maxAmount: 10, want: minAmount},
+		{amount: -3, maxAmount: 10, want: minAmount},
+	}
+
+	for _, test := range tests {
+		if got := clampAmount(test.amount, test.maxAmount); got != test.want {
+			t.Errorf("clampAmount(%d, %d) = %d, want %d", test.amount, test.maxAmount, got, test.want)
+		}
+	}
+}
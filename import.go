@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/archive"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/DeLucaJ/quotebot/internal/migration"
+	"github.com/bwmarrin/discordgo"
+	"io"
+	"log"
+	"strings"
+)
+
+// largest file accepted by /quote-admin import
+const maxImportSize = 8 * 1024 * 1024
+
+func adminImportHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, optionData *discordgo.ApplicationCommandInteractionDataOption) {
+	optionMap := makeOptionMap(optionData.Options)
+
+	attachmentID, _ := optionMap["file"].Value.(string)
+	attachment, ok := interaction.ApplicationCommandData().Resolved.Attachments[attachmentID]
+	if !ok {
+		response := ephemeralResponse("Sorry, I couldn't find the uploaded file")
+		respond(session, interaction, response)
+		return
+	}
+
+	// the router has deferred the response, so downloading and inserting a large file can take its time
+	response := importQuotes(manager, session, interaction, attachment)
+
+	respond(session, interaction, response)
+}
+
+// importQuotes reads an uploaded archive into the interaction's guild and describes the outcome
+func importQuotes(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, attachment *discordgo.MessageAttachment) discordgo.InteractionResponse {
+	if attachment.Size > maxImportSize {
+		return ephemeralResponse(fmt.Sprintf("Sorry, import files can be at most %d MB", maxImportSize/1024/1024))
+	}
+
+	file, err := downloadAttachment(session, attachment)
+	if err != nil {
+		log.Printf("Failed to download import file %s: %v", attachment.Filename, err)
+		return ephemeralResponse("Sorry, I couldn't download the uploaded file")
+	}
+
+	quotes, err := archive.Decode(archive.FormatOf(attachment.Filename), file)
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Sorry, I couldn't read %s: %v", attachment.Filename, err))
+	}
+
+	guild := manager.FindGuildMembers(interaction.GuildID)
+	importer := manager.AddUser(interaction.Member.User, guild)
+
+	report := migration.ImportQuotes(manager, guild, quotes, importer)
+	log.Printf("Imported %s into %s: %d imported, %d duplicates, %d empty, %d opted out, %d unknown statuses, %d failed, %d unmatched",
+		attachment.Filename, guild.Name, report.Imported, report.Duplicates, report.Empty,
+		report.OptedOut, report.Unknown, report.Failed, len(report.Unmatched))
+	dispatchNewQuotes(manager, session, report.Waiting, interaction.GuildID)
+
+	return importReportResponse(report)
+}
+
+func downloadAttachment(session *discordgo.Session, attachment *discordgo.MessageAttachment) ([]byte, error) {
+	response, err := session.Client.Get(attachment.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status %s", response.Status)
+	}
+	return io.ReadAll(io.LimitReader(response.Body, maxImportSize))
+}
+
+// importReportResponse summarizes an import, attaching the unmatched rows if there are any
+func importReportResponse(report migration.ImportReport) discordgo.InteractionResponse {
+	content := fmt.Sprintf("Imported %d quotes. Skipped %d duplicates and %d empty quotes.",
+		report.Imported, report.Duplicates, report.Empty)
+	if report.OptedOut > 0 {
+		content += fmt.Sprintf(" Skipped %d quotes of speakers who asked not to be quoted.", report.OptedOut)
+	}
+	if report.Unknown > 0 {
+		content += fmt.Sprintf(" Skipped %d quotes with a status I don't know.", report.Unknown)
+	}
+	if len(report.Waiting) > 0 {
+		content += fmt.Sprintf(" %d quotes are waiting on their speaker's consent or a moderator.", len(report.Waiting))
+	}
+	if report.Failed > 0 {
+		content += fmt.Sprintf(" %d quotes couldn't be stored, please try the import again.", report.Failed)
+	}
+
+	if len(report.Unmatched) == 0 {
+		return ephemeralResponse(content)
+	}
+
+	content += fmt.Sprintf(" %d quotes had speakers I couldn't match to a member: %s",
+		len(report.Unmatched), strings.Join(report.UnmatchedSpeakers(), ", "))
+	content = truncate(content, 2000)
+
+	var unmatchedRows bytes.Buffer
+	for _, unmatched := range report.Unmatched {
+		speaker := unmatched.Quote.SpeakerName
+		if len(unmatched.Quote.SpeakerDiscordID) > 0 {
+			speaker += " (" + unmatched.Quote.SpeakerDiscordID + ")"
+		}
+		_, _ = fmt.Fprintf(&unmatchedRows, "row %d: %s: \"%s\"\n", unmatched.Row, speaker, unmatched.Quote.Content)
+	}
+
+	response := ephemeralResponse(content)
+	response.Data.Files = []*discordgo.File{
+		{
+			Name:        "unmatched.txt",
+			ContentType: "text/plain",
+			Reader:      &unmatchedRows,
+		},
+	}
+	return response
+}
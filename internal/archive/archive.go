@@ -0,0 +1,275 @@
+// Package archive converts exported quotes to and from the file formats QuoteBot offers for backups
+package archive
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Format - a file format quotes can be archived in
+type Format string
+
+const (
+	JSON     Format = "json"
+	CSV      Format = "csv"
+	Markdown Format = "markdown"
+)
+
+// Formats - every supported Format, in the order they are offered to users
+var Formats = []Format{JSON, CSV, Markdown}
+
+// columns shared by the CSV and Markdown formats
+var columns = []string{
+	"guild",
+	"content",
+	"speaker-name",
+	"speaker-discord-id",
+	"submitter-name",
+	"submitter-discord-id",
+	"status",
+	"created-at",
+	"updated-at",
+}
+
+// Extension - the file extension used for a Format
+func (format Format) Extension() string {
+	if format == Markdown {
+		return "md"
+	}
+	return string(format)
+}
+
+// ContentType - the MIME type used for a Format
+func (format Format) ContentType() string {
+	switch format {
+	case CSV:
+		return "text/csv"
+	case Markdown:
+		return "text/markdown"
+	default:
+		return "application/json"
+	}
+}
+
+// FormatOf - guesses the Format of a file from its name, defaulting to JSON
+func FormatOf(fileName string) Format {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return CSV
+	case ".md", ".markdown":
+		return Markdown
+	default:
+		return JSON
+	}
+}
+
+// Encode - writes quotes in the given Format
+func Encode(format Format, quotes []data.QuoteExport) ([]byte, error) {
+	switch format {
+	case JSON:
+		return json.MarshalIndent(quotes, "", "\t")
+	case CSV:
+		return encodeCSV(quotes)
+	case Markdown:
+		return encodeMarkdown(quotes), nil
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+func row(quote data.QuoteExport) []string {
+	return []string{
+		quote.Guild,
+		quote.Content,
+		quote.SpeakerName,
+		quote.SpeakerDiscordID,
+		quote.SubmitterName,
+		quote.SubmitterDiscordID,
+		string(quote.Status),
+		quote.CreatedAt.Format(time.RFC3339),
+		quote.UpdatedAt.Format(time.RFC3339),
+	}
+}
+
+func encodeCSV(quotes []data.QuoteExport) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	if err := writer.Write(columns); err != nil {
+		return nil, err
+	}
+	for _, quote := range quotes {
+		if err := writer.Write(row(quote)); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+// markdownEscaper keeps cell content from breaking the table layout
+// line breaks become <br>, so a literal <br> is escaped to tell it apart; markdownUnescaper undoes every replacement
+var markdownEscaper = strings.NewReplacer("\\", "\\\\", "|", "\\|", "<br>", "\\<br>", "\r\n", "<br>", "\n", "<br>")
+
+func encodeMarkdown(quotes []data.QuoteExport) []byte {
+	var buffer bytes.Buffer
+
+	buffer.WriteString("| " + strings.Join(columns, " | ") + " |\n")
+	buffer.WriteString(strings.Repeat("| --- ", len(columns)) + "|\n")
+	for _, quote := range quotes {
+		cells := row(quote)
+		for index, cell := range cells {
+			cells[index] = markdownEscaper.Replace(cell)
+		}
+		buffer.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+
+	return buffer.Bytes()
+}
+
+// legacyQuote - the quote format of the original QuoteBot, which only knew speaker names
+type legacyQuote struct {
+	Speaker string `json:"Speaker"`
+	Text    string `json:"Text"`
+}
+
+// Decode - reads quotes written by Encode, or the legacy QuoteBot JSON format
+func Decode(format Format, file []byte) ([]data.QuoteExport, error) {
+	switch format {
+	case JSON:
+		return decodeJSON(file)
+	case CSV:
+		rows, err := csv.NewReader(bytes.NewReader(file)).ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		return decodeRows(rows)
+	case Markdown:
+		return decodeMarkdown(file)
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", format)
+	}
+}
+
+func decodeJSON(file []byte) ([]data.QuoteExport, error) {
+	var records []struct {
+		data.QuoteExport
+		legacyQuote
+	}
+	if err := json.Unmarshal(file, &records); err != nil {
+		return nil, err
+	}
+
+	quotes := make([]data.QuoteExport, len(records))
+	for index, record := range records {
+		quotes[index] = record.QuoteExport
+		if len(record.Content) == 0 && len(record.SpeakerName) == 0 {
+			quotes[index].Content = record.Text
+			quotes[index].SpeakerName = record.Speaker
+		}
+	}
+	return quotes, nil
+}
+
+// decodeRows reads table rows whose first row names the columns
+func decodeRows(rows [][]string) ([]data.QuoteExport, error) {
+	if len(rows) == 0 {
+		return []data.QuoteExport{}, nil
+	}
+
+	header := make(map[string]int, len(rows[0]))
+	for index, column := range rows[0] {
+		header[strings.ToLower(strings.TrimSpace(column))] = index
+	}
+	if _, ok := header["content"]; !ok {
+		return nil, fmt.Errorf("missing content column")
+	}
+
+	quotes := make([]data.QuoteExport, 0, len(rows)-1)
+	for _, cells := range rows[1:] {
+		cell := func(column string) string {
+			if index, ok := header[column]; ok && index < len(cells) {
+				return strings.TrimSpace(cells[index])
+			}
+			return ""
+		}
+
+		createdAt, _ := time.Parse(time.RFC3339, cell("created-at"))
+		updatedAt, _ := time.Parse(time.RFC3339, cell("updated-at"))
+		quotes = append(quotes, data.QuoteExport{
+			Guild:              cell("guild"),
+			Content:            cell("content"),
+			SpeakerName:        cell("speaker-name"),
+			SpeakerDiscordID:   cell("speaker-discord-id"),
+			SubmitterName:      cell("submitter-name"),
+			SubmitterDiscordID: cell("submitter-discord-id"),
+			Status:             data.QuoteStatus(cell("status")),
+			CreatedAt:          createdAt,
+			UpdatedAt:          updatedAt,
+		})
+	}
+	return quotes, nil
+}
+
+var markdownUnescaper = strings.NewReplacer("\\\\", "\\", "\\|", "|", "\\<", "<", "<br>", "\n")
+
+func decodeMarkdown(file []byte) ([]data.QuoteExport, error) {
+	var rows [][]string
+	separated := false
+
+	for _, line := range strings.Split(string(file), "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "|") {
+			continue
+		}
+		cells := splitMarkdownRow(line)
+		// only the row right below the header separates it, any later row is a quote whatever it holds
+		if len(rows) == 1 && !separated && separatorRow(cells) {
+			separated = true
+			continue
+		}
+		rows = append(rows, cells)
+	}
+
+	return decodeRows(rows)
+}
+
+// separatorRow reports whether every cell of a row is a run of dashes, optionally aligned with colons
+func separatorRow(cells []string) bool {
+	for _, cell := range cells {
+		dashes := strings.Trim(strings.TrimSpace(cell), ":")
+		if len(dashes) == 0 || strings.Trim(dashes, "-") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// splitMarkdownRow splits a table row on the pipes that were not escaped by encodeMarkdown
+func splitMarkdownRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+
+	var cells []string
+	var cell strings.Builder
+	for index := 0; index < len(line); index++ {
+		switch {
+		case line[index] == '\\' && index+1 < len(line):
+			cell.WriteByte(line[index])
+			cell.WriteByte(line[index+1])
+			index++
+		case line[index] == '|':
+			cells = append(cells, markdownUnescaper.Replace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(line[index])
+		}
+	}
+	return append(cells, markdownUnescaper.Replace(cell.String()))
+}
@@ -0,0 +1,214 @@
+package archive
+
+import (
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func exportedQuotes() []data.QuoteExport {
+	createdAt := time.Date(2023, 4, 5, 6, 7, 8, 0, time.UTC)
+	updatedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	return []data.QuoteExport{
+		{
+			Guild:              "Friends",
+			Content:            "Hello there",
+			SpeakerName:        "obi",
+			SpeakerDiscordID:   "100",
+			SubmitterName:      "anakin",
+			SubmitterDiscordID: "200",
+			Status:             data.QuoteApproved,
+			CreatedAt:          createdAt,
+			UpdatedAt:          updatedAt,
+		},
+		{
+			Guild:       "---",
+			Content:     "a | b\nsecond line",
+			SpeakerName: "pipe|name",
+			Status:      data.QuotePending,
+			CreatedAt:   createdAt,
+			UpdatedAt:   updatedAt,
+		},
+		{
+			Guild:       "Friends",
+			Content:     `literal <br> and \<br> and a \ backslash, "quoted", with, commas`,
+			SpeakerName: "obi",
+			Status:      data.QuoteConsent,
+			CreatedAt:   createdAt,
+			UpdatedAt:   updatedAt,
+		},
+		{
+			Guild:       "Friends",
+			Content:     `ends in a backslash \`,
+			SpeakerName: "obi",
+			Status:      data.QuoteRejected,
+			CreatedAt:   createdAt,
+			UpdatedAt:   updatedAt,
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		quotes []data.QuoteExport
+	}{
+		{name: "quotes", quotes: exportedQuotes()},
+		{name: "no quotes", quotes: []data.QuoteExport{}},
+	}
+
+	for _, format := range Formats {
+		for _, test := range tests {
+			t.Run(string(format)+"/"+test.name, func(t *testing.T) {
+				file, err := Encode(format, test.quotes)
+				if err != nil {
+					t.Fatalf("Encode returned %v", err)
+				}
+
+				decoded, err := Decode(format, file)
+				if err != nil {
+					t.Fatalf("Decode returned %v", err)
+				}
+				if !reflect.DeepEqual(decoded, test.quotes) {
+					t.Errorf("Decode(Encode(quotes)) = %+v, want %+v\nfile:\n%s", decoded, test.quotes, file)
+				}
+			})
+		}
+	}
+}
+
+func TestEncodeMarkdownRows(t *testing.T) {
+	file, err := Encode(Markdown, exportedQuotes())
+	if err != nil {
+		t.Fatalf("Encode returned %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(file)), "\n")
+	if len(lines) != 2+len(exportedQuotes()) {
+		t.Fatalf("got %d lines, want one per quote below the header and separator:\n%s", len(lines), file)
+	}
+	if !strings.Contains(lines[3], `a \| b<br>second line`) {
+		t.Errorf("pipes and line breaks were not escaped: %s", lines[3])
+	}
+	if !strings.Contains(lines[4], `literal \<br> and \\\<br>`) {
+		t.Errorf("literal <br> was not escaped: %s", lines[4])
+	}
+}
+
+func TestDecodeLegacyJSON(t *testing.T) {
+	file := []byte(`[{"Speaker": "obi", "Text": "Hello there"}, {"Speaker": "", "Text": "nobody said this"}]`)
+
+	quotes, err := Decode(JSON, file)
+	if err != nil {
+		t.Fatalf("Decode returned %v", err)
+	}
+
+	want := []data.QuoteExport{
+		{Content: "Hello there", SpeakerName: "obi"},
+		{Content: "nobody said this"},
+	}
+	if !reflect.DeepEqual(quotes, want) {
+		t.Errorf("Decode = %+v, want %+v", quotes, want)
+	}
+}
+
+func TestDecodeTables(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		file    string
+		want    []data.QuoteExport
+		wantErr bool
+	}{
+		{
+			name:   "csv with reordered and missing columns",
+			format: CSV,
+			file:   "Speaker-Name,content\nobi,Hello there\n",
+			want:   []data.QuoteExport{{Content: "Hello there", SpeakerName: "obi"}},
+		},
+		{
+			name:    "csv without content",
+			format:  CSV,
+			file:    "speaker-name\nobi\n",
+			wantErr: true,
+		},
+		{
+			name:    "malformed csv",
+			format:  CSV,
+			file:    "content\n\"unterminated\n",
+			wantErr: true,
+		},
+		{
+			name:   "empty csv",
+			format: CSV,
+			file:   "",
+			want:   []data.QuoteExport{},
+		},
+		{
+			name:   "markdown with an aligned separator",
+			format: Markdown,
+			file:   "| content | speaker-name |\n| :--- | ---: |\n| Hello there | obi |\n",
+			want:   []data.QuoteExport{{Content: "Hello there", SpeakerName: "obi"}},
+		},
+		{
+			name:   "markdown quote that looks like a separator",
+			format: Markdown,
+			file:   "| content | speaker-name |\n| --- | --- |\n| --- | --- |\n",
+			want:   []data.QuoteExport{{Content: "---", SpeakerName: "---"}},
+		},
+		{
+			name:   "markdown surrounded by text",
+			format: Markdown,
+			file:   "# Quotes\n\n| content |\n| --- |\n| Hello there |\n\nThe end\n",
+			want:   []data.QuoteExport{{Content: "Hello there"}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			quotes, err := Decode(test.format, []byte(test.file))
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("Decode = %+v, want an error", quotes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode returned %v", err)
+			}
+			if !reflect.DeepEqual(quotes, test.want) {
+				t.Errorf("Decode = %+v, want %+v", quotes, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		fileName string
+		want     Format
+	}{
+		{fileName: "quotes.json", want: JSON},
+		{fileName: "quotes.CSV", want: CSV},
+		{fileName: "quotes.md", want: Markdown},
+		{fileName: "quotes.markdown", want: Markdown},
+		{fileName: "quotes", want: JSON},
+		{fileName: "quotes.txt", want: JSON},
+	}
+
+	for _, test := range tests {
+		if got := FormatOf(test.fileName); got != test.want {
+			t.Errorf("FormatOf(%q) = %q, want %q", test.fileName, got, test.want)
+		}
+	}
+
+	// every format reads back from the file name it is saved under
+	for _, format := range Formats {
+		if got := FormatOf("quotes." + format.Extension()); got != format {
+			t.Errorf("FormatOf(quotes.%s) = %q, want %q", format.Extension(), got, format)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package data
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"strings"
+	"time"
+)
+
+// AliasKind - where a UserAlias was seen
+type AliasKind string
+
+const (
+	UsernameAlias   AliasKind = "username"     // the account name of the user
+	GlobalNameAlias AliasKind = "display-name" // the display name of the user's account
+	NicknameAlias   AliasKind = "nickname"     // the nickname of the user in the guild
+)
+
+// UserAlias - a name a User has gone by, kept after they change it
+type UserAlias struct {
+	gorm.Model
+	UserID uint      `gorm:"uniqueIndex:idx_user_alias"` // database ID of the User that had the name
+	User   User      // the User that had the name
+	Alias  string    `gorm:"uniqueIndex:idx_user_alias"` // the name itself
+	Kind   AliasKind // where the name was seen
+}
+
+// RecordAlias - remembers a name a user has gone by, refreshing when it was last seen
+func (manager Manager) RecordAlias(user User, alias string, kind AliasKind) {
+	alias = strings.TrimSpace(alias)
+	if user.ID == 0 || len(alias) == 0 {
+		return
+	}
+
+	manager.recordAliases([]UserAlias{{UserID: user.ID, Alias: alias, Kind: kind}})
+}
+
+// recordAliases upserts many aliases at once, the aliases must not repeat a user and name
+func (manager Manager) recordAliases(aliases []UserAlias) {
+	if len(aliases) == 0 {
+		return
+	}
+
+	result := manager.Database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "alias"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"updated_at": time.Now(), "deleted_at": nil}),
+	}).CreateInBatches(&aliases, batchSize)
+	if result.Error != nil {
+		log.Println("Error recording aliases: ", result.Error)
+	}
+}
+
+// memberAliases lists the distinct names of a member for recordAliases
+func memberAliases(user User, member *discordgo.Member) []UserAlias {
+	names := []struct {
+		alias string
+		kind  AliasKind
+	}{
+		{member.User.Username, UsernameAlias},
+		{member.User.GlobalName, GlobalNameAlias},
+		{member.Nick, NicknameAlias},
+	}
+
+	seen := make(map[string]bool, len(names))
+	var aliases []UserAlias
+	for _, name := range names {
+		alias := strings.TrimSpace(name.alias)
+		if len(alias) == 0 || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		aliases = append(aliases, UserAlias{UserID: user.ID, Alias: alias, Kind: name.kind})
+	}
+	return aliases
+}
+
+// FindGuildAliases - every alias of the users of a guild, most recently seen first
+func (manager Manager) FindGuildAliases(guild Guild) []UserAlias {
+	var aliases []UserAlias
+	result := manager.Database.
+		Joins("User").
+		Where("\"User\".guild_id = ?", guild.ID).
+		Order("user_aliases.updated_at DESC").
+		Find(&aliases)
+	if result.Error != nil {
+		log.Println("Error retrieving aliases: ", result.Error)
+	}
+	return aliases
+}
@@ -0,0 +1,83 @@
+package data
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+)
+
+// OutsidePolicy - how quote commands are answered in channels the guild doesn't allow them in
+type OutsidePolicy string
+
+const (
+	EphemeralOutside OutsidePolicy = "ephemeral" // the answer is only shown to the member who asked
+	RedirectOutside  OutsidePolicy = "redirect"  // the answer is posted in an allowed channel instead
+)
+
+// ChannelRule - a channel of a guild on its allowlist or its denylist
+type ChannelRule struct {
+	gorm.Model
+	GuildID   uint   `gorm:"uniqueIndex:idx_channel_rule"` // the ID of the Guild the channel belongs to
+	ChannelID string `gorm:"uniqueIndex:idx_channel_rule"` // Discord ID of the channel
+	Allow     bool   // true for the allowlist, false for the denylist
+}
+
+// ChannelRules - the allowlist and denylist of a guild
+type ChannelRules []ChannelRule
+
+// Allows - whether quote commands may be answered in a channel
+// denied channels never are; once a guild allows any channel, only the allowed ones are
+func (rules ChannelRules) Allows(channelID string) bool {
+	allowlist := false
+	for _, rule := range rules {
+		if rule.ChannelID == channelID {
+			return rule.Allow
+		}
+		allowlist = allowlist || rule.Allow
+	}
+	return !allowlist
+}
+
+// Allowed - the channels on the allowlist
+func (rules ChannelRules) Allowed() []string {
+	var channelIDs []string
+	for _, rule := range rules {
+		if rule.Allow {
+			channelIDs = append(channelIDs, rule.ChannelID)
+		}
+	}
+	return channelIDs
+}
+
+// SetChannelRule - puts a channel on the allowlist or the denylist of a guild, moving it if it was on the other
+func (manager Manager) SetChannelRule(guild Guild, channelID string, allow bool) {
+	rule := ChannelRule{GuildID: guild.ID, ChannelID: channelID, Allow: allow}
+	result := manager.Database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "guild_id"}, {Name: "channel_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"allow", "updated_at", "deleted_at"}),
+	}).Create(&rule)
+	if result.Error != nil {
+		log.Println("Error saving channel rule: ", result.Error)
+	}
+}
+
+// RemoveChannelRule - takes a channel off the lists of a guild, reporting whether it was on one
+func (manager Manager) RemoveChannelRule(guild Guild, channelID string) bool {
+	result := manager.Database.Unscoped().
+		Where(&ChannelRule{GuildID: guild.ID, ChannelID: channelID}).
+		Delete(&ChannelRule{})
+	if result.Error != nil {
+		log.Println("Error removing channel rule: ", result.Error)
+	}
+	return result.RowsAffected > 0
+}
+
+// FindChannelRules - the allowlist and denylist of a guild
+func (manager Manager) FindChannelRules(guild Guild) ChannelRules {
+	var rules ChannelRules
+	result := manager.Database.Where(&ChannelRule{GuildID: guild.ID}).Order("allow DESC, channel_id").Find(&rules)
+	if result.Error != nil {
+		log.Println("Error retrieving channel rules: ", result.Error)
+	}
+	return rules
+}
@@ -0,0 +1,239 @@
+package data
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErasedUserName - the name given to User entries whose owner erased their data
+const ErasedUserName = "Deleted User"
+
+// erasedDiscordID - the Discord ID an erased User entry is left with, unique per entry
+// so erasing several users of a guild doesn't break the unique index of users per guild
+var erasedDiscordID = gorm.Expr("CONCAT('erased:', id)")
+
+// UserDataExport - everything stored about a single Discord user across guilds
+type UserDataExport struct {
+	DiscordID   string                `json:"discord-id"`
+	Profiles    []UserProfileExport   `json:"profiles"`
+	Spoken      []QuoteExport         `json:"spoken"`
+	Submitted   []QuoteExport         `json:"submitted"`
+	LegacyNames []LegacyNameMapExport `json:"legacy-names"`
+}
+
+// LegacyNameMapExport - a legacy QuoteBot speaker name a migration maps to the user
+type LegacyNameMapExport struct {
+	Guild    string `json:"guild"`
+	OldName  string `json:"old-name"`
+	UserName string `json:"user-name"`
+}
+
+// userNameMapsCondition matches the migration name maps of a Discord user: the ones mapped to them directly,
+// and the ones from a migration config, which only know the name of one of their User entries
+const userNameMapsCondition = "migration_name_maps.user_discord_id = ? OR " +
+	"(migration_name_maps.user_discord_id = '' AND migration_name_maps.user_name IN (?))"
+
+// UserProfileExport - a User entry of an export
+type UserProfileExport struct {
+	Guild          string    `json:"guild"`
+	Name           string    `json:"name"`
+	GlobalName     string    `json:"display-name"`
+	Nickname       string    `json:"nickname"`
+	OptOut         bool      `json:"opt-out"`
+	RequireConsent bool      `json:"require-consent"`
+	Aliases        []string  `json:"aliases"`
+	CreatedAt      time.Time `json:"created-at"`
+}
+
+// QuoteExport - a Quote entry of an export
+type QuoteExport struct {
+	Guild              string      `json:"guild"`
+	Content            string      `json:"content"`
+	SpeakerName        string      `json:"speaker-name"`
+	SpeakerDiscordID   string      `json:"speaker-discord-id"`
+	SubmitterName      string      `json:"submitter-name"`
+	SubmitterDiscordID string      `json:"submitter-discord-id"`
+	Status             QuoteStatus `json:"status"`
+	CreatedAt          time.Time   `json:"created-at"`
+	UpdatedAt          time.Time   `json:"updated-at"`
+}
+
+func newQuoteExport(quote Quote) QuoteExport {
+	return QuoteExport{
+		Guild:              quote.Guild.Name,
+		Content:            quote.Content,
+		SpeakerName:        quote.Speaker.Name,
+		SpeakerDiscordID:   quote.Speaker.DiscordID,
+		SubmitterName:      quote.Submitter.Name,
+		SubmitterDiscordID: quote.Submitter.DiscordID,
+		Status:             quote.Status,
+		CreatedAt:          quote.CreatedAt,
+		UpdatedAt:          quote.UpdatedAt,
+	}
+}
+
+// ExportGuildQuotes - collects every Quote entry of a guild, whatever its status
+func (manager Manager) ExportGuildQuotes(guildID string) []QuoteExport {
+	// struct conditions skip zero values, so an unknown guild would export the quotes of every guild
+	guild := manager.FindGuildEntry(guildID)
+	if guild.ID == 0 {
+		return []QuoteExport{}
+	}
+
+	var quotes []Quote
+	result := manager.Database.
+		Where("guild_id = ?", guild.ID).
+		Preload(clause.Associations).
+		Order("created_at").
+		Find(&quotes)
+	if result.Error != nil {
+		log.Println("Error retrieving quotes of guild ", guildID, result.Error)
+	}
+
+	exports := make([]QuoteExport, len(quotes))
+	for index, quote := range quotes {
+		exports[index] = newQuoteExport(quote)
+	}
+	return exports
+}
+
+// findUserEntries - every User entry of a Discord user, in any guild
+func (manager Manager) findUserEntries(discordID string) []User {
+	var users []User
+	result := manager.Database.
+		Where(&User{DiscordID: discordID}).
+		Preload("Guild").
+		Preload("Aliases").
+		Find(&users)
+
+	if result.Error != nil {
+		log.Println("Error retrieving users of ID ", discordID, result.Error)
+	}
+	return users
+}
+
+// ExportUserData - collects every User and Quote entry associated with a Discord user
+func (manager Manager) ExportUserData(discordID string) UserDataExport {
+	export := UserDataExport{
+		DiscordID:   discordID,
+		Profiles:    []UserProfileExport{},
+		Spoken:      []QuoteExport{},
+		Submitted:   []QuoteExport{},
+		LegacyNames: []LegacyNameMapExport{},
+	}
+
+	users := manager.findUserEntries(discordID)
+	if len(users) == 0 {
+		return export
+	}
+
+	userIDs := make([]uint, len(users))
+	for index, user := range users {
+		userIDs[index] = user.ID
+		aliases := make([]string, len(user.Aliases))
+		for aliasIndex, alias := range user.Aliases {
+			aliases[aliasIndex] = alias.Alias
+		}
+		export.Profiles = append(export.Profiles, UserProfileExport{
+			Guild:          user.Guild.Name,
+			Name:           user.Name,
+			GlobalName:     user.GlobalName,
+			Nickname:       user.Nickname,
+			OptOut:         user.OptOut,
+			RequireConsent: user.RequireConsent,
+			Aliases:        aliases,
+			CreatedAt:      user.CreatedAt,
+		})
+	}
+
+	var quotes []Quote
+	result := manager.Database.
+		Where("speaker_id IN ? OR submitter_id IN ?", userIDs, userIDs).
+		Preload(clause.Associations).
+		Find(&quotes)
+	if result.Error != nil {
+		log.Println("Error retrieving quotes of user ", discordID, result.Error)
+	}
+
+	for _, quote := range quotes {
+		if quote.Speaker.DiscordID == discordID {
+			export.Spoken = append(export.Spoken, newQuoteExport(quote))
+		}
+		if quote.Submitter.DiscordID == discordID {
+			export.Submitted = append(export.Submitted, newQuoteExport(quote))
+		}
+	}
+
+	names := manager.Database.Model(&User{}).Select("name").Where("discord_id = ?", discordID)
+	result = manager.Database.Model(&MigrationNameMap{}).
+		Select("COALESCE(guilds.name, migration_jobs.guild_discord_id) AS guild, migration_name_maps.old_name, migration_name_maps.user_name").
+		Joins("JOIN migration_jobs ON migration_jobs.id = migration_name_maps.migration_job_id").
+		Joins("LEFT JOIN guilds ON guilds.discord_id = migration_jobs.guild_discord_id").
+		Where(userNameMapsCondition, discordID, names).
+		Scan(&export.LegacyNames)
+	if result.Error != nil {
+		log.Println("Error retrieving legacy names of user ", discordID, result.Error)
+	}
+	return export
+}
+
+// EraseUserData - permanently deletes the quotes, aliases and legacy name maps of a Discord user and anonymizes their User entries
+// quotes they submitted about others are kept, attributed to an anonymous submitter
+func (manager Manager) EraseUserData(discordID string) (int64, int64, error) {
+	var erasedQuotes, erasedUsers int64
+
+	if len(discordID) == 0 {
+		return 0, 0, errors.New("no Discord ID to erase")
+	}
+
+	err := manager.Database.Transaction(func(tx *gorm.DB) error {
+		userIDs := tx.Model(&User{}).Select("id").Where(&User{DiscordID: discordID})
+
+		result := tx.Unscoped().Where("speaker_id IN (?)", userIDs).Delete(&Quote{})
+		if result.Error != nil {
+			return result.Error
+		}
+		erasedQuotes = result.RowsAffected
+
+		result = tx.Unscoped().Where("user_id IN (?)", userIDs).Delete(&UserAlias{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		// before the User entries lose the names that maps from a migration config refer to
+		names := tx.Model(&User{}).Select("name").Where("discord_id = ?", discordID)
+		result = tx.Unscoped().Where(userNameMapsCondition, discordID, names).Delete(&MigrationNameMap{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Unscoped().Model(&User{}).
+			Where(&User{DiscordID: discordID}).
+			Updates(map[string]interface{}{
+				"name":            ErasedUserName,
+				"global_name":     "",
+				"nickname":        "",
+				"avatar_hash":     "",
+				"discord_id":      erasedDiscordID,
+				"opt_out":         true,
+				"require_consent": false,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		erasedUsers = result.RowsAffected
+		return nil
+	})
+
+	if err != nil {
+		log.Println("Error erasing user data: ", err)
+		return 0, 0, err
+	}
+
+	log.Printf("Erased data of user %s: %d quotes, %d users", discordID, erasedQuotes, erasedUsers)
+	return erasedQuotes, erasedUsers, nil
+}
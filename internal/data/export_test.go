@@ -0,0 +1,117 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+// guildAnswers answers guild lookups with a single guild of database ID 3, known by Discord ID "guild"
+func guildAnswers(statement fakeStatement) fakeAnswer {
+	if statement.mentions(`FROM "guilds"`) && statement.has("guild") {
+		return fakeAnswer{columns: []string{"id", "discord_id", "name"}, rows: [][]driver.Value{{int64(3), "guild", "Friends"}}}
+	}
+	return fakeAnswer{}
+}
+
+func TestGuildScopedQueries(t *testing.T) {
+	tests := []struct {
+		name    string
+		guildID string
+		run     func(manager Manager) int
+	}{
+		{name: "export of an unknown guild", guildID: "unknown", run: func(manager Manager) int {
+			return len(manager.ExportGuildQuotes("unknown"))
+		}},
+		{name: "export of a known guild", guildID: "guild", run: func(manager Manager) int {
+			return len(manager.ExportGuildQuotes("guild"))
+		}},
+		{name: "search of an unknown guild", guildID: "unknown", run: func(manager Manager) int {
+			return len(manager.SearchQuotes("unknown", "hello", 5))
+		}},
+		{name: "search of a known guild", guildID: "guild", run: func(manager Manager) int {
+			return len(manager.SearchQuotes("guild", "hello", 5))
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager, database := newFakeManager(t, func(statement fakeStatement) fakeAnswer {
+				if statement.mentions(`FROM "quotes"`) {
+					return fakeAnswer{columns: []string{"id", "guild_id", "speaker_id", "content"}, rows: [][]driver.Value{{int64(1), int64(3), int64(4), "hello"}}}
+				}
+				return guildAnswers(statement)
+			})
+
+			found := test.run(manager)
+			queries := database.sent(`FROM "quotes"`)
+
+			if test.guildID != "guild" {
+				// an unknown guild has no ID, which must not turn into a query without a guild condition
+				if found != 0 || len(queries) != 0 {
+					t.Errorf("found %d quotes with %+v, want no quote queries", found, queries)
+				}
+				return
+			}
+
+			if found != 1 {
+				t.Errorf("found %d quotes, want 1", found)
+			}
+			for _, query := range queries {
+				if !query.mentions("guild_id = $") || !query.has(int64(3)) {
+					t.Errorf("quote query %q %v isn't limited to guild 3", query.query, query.args)
+				}
+			}
+		})
+	}
+}
+
+func TestEraseUserData(t *testing.T) {
+	manager, database := newFakeManager(t, func(statement fakeStatement) fakeAnswer {
+		if statement.mentions("UPDATE") || statement.mentions("DELETE") {
+			return fakeAnswer{affected: 1}
+		}
+		return fakeAnswer{}
+	})
+
+	if _, _, err := manager.EraseUserData("100"); err != nil {
+		t.Fatalf("EraseUserData returned %v", err)
+	}
+
+	var order []string
+	for _, statement := range database.sent("") {
+		switch {
+		case statement.mentions(`DELETE FROM "migration_name_maps"`, "user_discord_id = $", "user_name IN (SELECT"):
+			if !statement.has("100") {
+				t.Errorf("name maps were deleted without the user's ID: %v", statement.args)
+			}
+			order = append(order, "name maps")
+		case statement.mentions(`UPDATE "users"`, "avatar_hash"):
+			order = append(order, "users")
+		}
+	}
+	if len(order) != 2 || order[0] != "name maps" {
+		t.Errorf("erased %v, want the name maps deleted before the users are anonymized", order)
+	}
+}
+
+func TestExportUserDataLegacyNames(t *testing.T) {
+	manager, database := newFakeManager(t, func(statement fakeStatement) fakeAnswer {
+		switch {
+		case statement.mentions(`FROM "users"`) && !statement.mentions("migration_name_maps"):
+			return fakeAnswer{columns: []string{"id", "discord_id", "name"}, rows: [][]driver.Value{{int64(4), "100", "obi"}}}
+		case statement.mentions(`FROM "migration_name_maps"`):
+			return fakeAnswer{columns: []string{"guild", "old_name", "user_name"}, rows: [][]driver.Value{{"Friends", "Ben", "obi"}}}
+		}
+		return fakeAnswer{}
+	})
+
+	export := manager.ExportUserData("100")
+
+	want := []LegacyNameMapExport{{Guild: "Friends", OldName: "Ben", UserName: "obi"}}
+	if len(export.LegacyNames) != 1 || export.LegacyNames[0] != want[0] {
+		t.Errorf("ExportUserData legacy names = %+v, want %+v", export.LegacyNames, want)
+	}
+	if queries := database.sent(`FROM "migration_name_maps"`); len(queries) != 1 || !queries[0].has("100") {
+		t.Errorf("legacy names were queried with %+v, want one query for the user's ID", queries)
+	}
+}
@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// fakeStatement - a statement a Manager sent to the database
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+// mentions is whether the statement's SQL contains every fragment
+func (statement fakeStatement) mentions(fragments ...string) bool {
+	for _, fragment := range fragments {
+		if !strings.Contains(statement.query, fragment) {
+			return false
+		}
+	}
+	return true
+}
+
+// has is whether one of the statement's arguments is the value
+func (statement fakeStatement) has(value driver.Value) bool {
+	return slices.Contains(statement.args, value)
+}
+
+// fakeAnswer - the rows a query returns, or the number of rows a statement changes
+type fakeAnswer struct {
+	columns  []string
+	rows     [][]driver.Value
+	affected int64
+}
+
+// fakeDatabase - a database/sql driver that records every statement and answers it from a script,
+// so the SQL a Manager sends can be checked without a Postgres server
+type fakeDatabase struct {
+	mutex      sync.Mutex
+	statements []fakeStatement
+	answer     func(statement fakeStatement) fakeAnswer
+}
+
+// newFakeManager - a Manager on a fake database, statements the script doesn't know get no rows
+func newFakeManager(t *testing.T, answer func(statement fakeStatement) fakeAnswer) (Manager, *fakeDatabase) {
+	t.Helper()
+	if answer == nil {
+		answer = func(fakeStatement) fakeAnswer { return fakeAnswer{} }
+	}
+	database := &fakeDatabase{answer: answer}
+
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(database)}), &gorm.Config{
+		Logger:               logger.Discard,
+		DisableAutomaticPing: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Manager{Database: db}, database
+}
+
+// sent - the statements whose SQL contains every fragment
+func (database *fakeDatabase) sent(fragments ...string) []fakeStatement {
+	database.mutex.Lock()
+	defer database.mutex.Unlock()
+
+	var statements []fakeStatement
+	for _, statement := range database.statements {
+		if statement.mentions(fragments...) {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+func (database *fakeDatabase) run(query string, args []driver.NamedValue) fakeAnswer {
+	statement := fakeStatement{query: query}
+	for _, arg := range args {
+		statement.args = append(statement.args, arg.Value)
+	}
+
+	database.mutex.Lock()
+	database.statements = append(database.statements, statement)
+	database.mutex.Unlock()
+	return database.answer(statement)
+}
+
+func (database *fakeDatabase) Connect(context.Context) (driver.Conn, error) {
+	return fakeConn{database}, nil
+}
+
+func (database *fakeDatabase) Driver() driver.Driver {
+	return fakeDriver{database}
+}
+
+type fakeDriver struct {
+	database *fakeDatabase
+}
+
+func (fakeDriver fakeDriver) Open(string) (driver.Conn, error) {
+	return fakeConn(fakeDriver), nil
+}
+
+type fakeConn struct {
+	database *fakeDatabase
+}
+
+func (conn fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("the fake database doesn't prepare statements")
+}
+
+func (conn fakeConn) Close() error {
+	return nil
+}
+
+func (conn fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+func (conn fakeConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	answer := conn.database.run(query, args)
+	return &fakeRows{columns: answer.columns, rows: answer.rows}, nil
+}
+
+func (conn fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(conn.database.run(query, args).affected), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (rows *fakeRows) Columns() []string {
+	return rows.columns
+}
+
+func (rows *fakeRows) Close() error {
+	return nil
+}
+
+func (rows *fakeRows) Next(dest []driver.Value) error {
+	if len(rows.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, rows.rows[0])
+	rows.rows = rows.rows[1:]
+	return nil
+}
@@ -2,13 +2,16 @@ package data
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
 // Guild - Represents a Discord Server associated with this bot
 type Guild struct {
 	gorm.Model
-	DiscordID string  // The Discord ID of the guild
-	Name      string  // name of the guild
-	Users     []User  // All the User entries in the Guild
-	Quotes    []Quote // All the Quote entries in the Guild
+	DiscordID string        `gorm:"uniqueIndex"` // The Discord ID of the guild
+	Name      string        // name of the guild
+	LeftAt    *time.Time    // when the bot was removed from the guild, nil while it is a member
+	Settings  GuildSettings // the preferences of the Guild
+	Users     []User        // All the User entries in the Guild
+	Quotes    []Quote       // All the Quote entries in the Guild
 }
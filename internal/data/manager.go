@@ -2,12 +2,15 @@ package data
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"log"
 	"math/rand"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/bwmarrin/discordgo"
@@ -20,10 +23,22 @@ type Manager struct {
 	Database   *gorm.DB
 }
 
-// Start - Starts the boss and initializes the Database connection
+// Start - Starts the boss, initializes the Database connection and brings the schema up to date
 //
 //	uri string: the uri for the Database from config.json
 func Start(dsn string) Manager {
+	manager := Open(dsn)
+
+	// Initialize the Database
+	err := migrateSchema(manager.Database)
+	if err != nil {
+		log.Println("Error creating Database: " + err.Error())
+	}
+	return manager
+}
+
+// Open - initializes the Database connection without touching the schema, for tools that only read
+func Open(dsn string) Manager {
 	log.Println("Initializing Postgresql Client")
 
 	//// Connect to Postgres
@@ -36,12 +51,6 @@ func Start(dsn string) Manager {
 		log.Println("Error connecting to Postgres Client: " + err.Error())
 	}
 
-	// Initialize the Database
-	//err = db.AutoMigrate(&Guild{}, &User{}, &Quote{})
-	//if err != nil {
-	//	log.Println("Error creating Database: " + err.Error())
-	//}
-
 	// initializes the singleton Manager
 	return Manager{
 		Context:    ctx,
@@ -56,24 +65,32 @@ func (manager Manager) Shutdown() {
 	defer cancel()
 }
 
-// AddGuild - adds a guild to the Database
-func (manager Manager) AddGuild(guild *discordgo.Guild) {
+// AddGuild - adds a guild to the Database, or refreshes its name and marks it joined again if it exists
+func (manager Manager) AddGuild(guild *discordgo.Guild) Guild {
 	guildEntry := Guild{
 		DiscordID: guild.ID,
 		Name:      guild.Name,
 	}
-	manager.Database.Create(&guildEntry)
+	manager.insertGuild(&guildEntry)
+	return guildEntry
 }
 
-// AddUser - adds a user to the Database
-func (manager Manager) AddUser(user *discordgo.User, guild Guild) {
+// AddUser - adds a user to the Database unless they are already stored, returning their entry either way
+func (manager Manager) AddUser(user *discordgo.User, guild Guild) User {
 	userEntry := User{
-		Name:      user.Username,
-		DiscordID: user.ID,
-		GuildID:   guild.ID,
+		Name:       user.Username,
+		GlobalName: user.GlobalName,
+		AvatarHash: user.Avatar,
+		DiscordID:  user.ID,
+		GuildID:    guild.ID,
 	}
 
-	manager.insertUser(userEntry)
+	if !manager.insertUser(&userEntry) {
+		return manager.FindUser(user.ID, guild.ID)
+	}
+	manager.RecordAlias(userEntry, user.Username, UsernameAlias)
+	manager.RecordAlias(userEntry, user.GlobalName, GlobalNameAlias)
+	return userEntry
 }
 
 // AddQuote - adds a Quote to the Database
@@ -86,23 +103,27 @@ func (manager Manager) AddQuote(content string, speaker *discordgo.User, submitt
 
 	guildEntry := manager.FindGuild(guildID)
 
-	// Check if speaker user exists, if not make that user
-	if !manager.UserExists(speaker.ID, guildEntry) {
-		manager.AddUser(speaker, guildEntry)
-	}
-	speakerEntry := manager.FindUser(speaker.ID, guildEntry.ID)
+	// Makes the speaker user if they don't exist yet
+	speakerEntry := manager.AddUser(speaker, guildEntry)
 
-	if manager.QuoteExists(Quote{Content: content, SpeakerID: speakerEntry.ID}) {
+	if speakerEntry.OptOut {
 		return Quote{
-			Content: "Sorry, but a quote with that content already exists for this user",
+			Content: fmt.Sprintf("Sorry, but %s has asked not to be quoted", speakerEntry.DisplayName(guildEntry.Settings.Names())),
 		}
 	}
 
-	// Check if submitter user exists, if not make that user
-	if !manager.UserExists(submitter.ID, guildEntry) {
-		manager.AddUser(submitter, guildEntry)
+	if manager.QuoteExists(Quote{Content: content, SpeakerID: speakerEntry.ID, GuildID: guildEntry.ID}) {
+		return duplicateQuote()
+	}
+
+	// Makes the submitter user if they don't exist yet
+	submitterEntry := manager.AddUser(submitter, guildEntry)
+
+	status := submissionStatus(speakerEntry, submitterEntry, guildEntry)
+	similar := manager.FindSimilarQuote(Quote{Content: content, SpeakerID: speakerEntry.ID, GuildID: guildEntry.ID})
+	if similar.ID != 0 {
+		status = QuoteHeld
 	}
-	submitterEntry := manager.FindUser(submitter.ID, guildEntry.ID)
 
 	quote := Quote{
 		Content:     content,
@@ -111,27 +132,86 @@ func (manager Manager) AddQuote(content string, speaker *discordgo.User, submitt
 		SubmitterID: submitterEntry.ID,
 		Submitter:   submitterEntry,
 		GuildID:     guildEntry.ID,
+		Guild:       guildEntry,
+		Status:      status,
 	}
 
-	manager.insertQuote(quote)
-
-	// Temporary Timestamp to account for the add event bug
-	quote.CreatedAt = time.Now()
+	// a concurrent submission of the same quote may have won the race since the check above
+	if added, _ := manager.insertQuote(&quote); !added {
+		return duplicateQuote()
+	}
 
 	return quote
 }
 
+// submissionStatus - the status a new quote starts in, depending on the speaker's and the guild's settings
+func submissionStatus(speaker User, submitter User, guild Guild) QuoteStatus {
+	if speaker.RequireConsent && speaker.ID != submitter.ID {
+		return QuoteConsent
+	} else if guild.Settings.RequireApproval {
+		return QuotePending
+	}
+	return QuoteApproved
+}
+
+// duplicateQuote - the Quote returned when a speaker already has a quote
+func duplicateQuote() Quote {
+	return Quote{
+		Content: "Sorry, but a quote with that content already exists for this user",
+	}
+}
+
 func (manager Manager) AddLegacyQuote(content string, speaker User, submitter User, guild Guild) Quote {
+	quote, _ := manager.ImportQuote(content, speaker, submitter, guild, QuoteApproved, time.Time{})
+	return quote
+}
+
+// ErrOptedOut - the speaker of an imported quote has asked not to be quoted
+var ErrOptedOut = errors.New("the speaker has opted out of being quoted")
+
+// ErrUnknownStatus - an imported quote has a status QuoteBot doesn't know
+var ErrUnknownStatus = errors.New("unknown quote status")
+
+// importStatus - the status an imported quote starts in
+// quotes keep the status they were exported with, and files without statuses only hold approved quotes;
+// held quotes go to the moderators instead, as nobody is asked to confirm them and they would be purged as unconfirmed;
+// approved quotes about speakers who require consent wait for it, like new submissions do
+func importStatus(exported QuoteStatus, speaker User, submitter User) (QuoteStatus, bool) {
+	if len(exported) == 0 {
+		exported = QuoteApproved
+	}
+	if !slices.Contains(QuoteStatuses, exported) {
+		return "", false
+	}
+	if exported == QuoteHeld {
+		return QuotePending, true
+	}
+	if exported == QuoteApproved && speaker.RequireConsent && speaker.ID != submitter.ID {
+		return QuoteConsent, true
+	}
+	return exported, true
+}
+
+// ImportQuote - adds a Quote from an outside source, keeping its status and original timestamp if known
+// a duplicate returns a Quote without a speaker and no error
+func (manager Manager) ImportQuote(content string, speaker User, submitter User, guild Guild, status QuoteStatus, createdAt time.Time) (Quote, error) {
 	if len(content) == 0 {
 		return Quote{
 			Content: "Sorry, but I can't accept empty quotes or quotes with only embedded content",
-		}
+		}, nil
 	}
 
-	if manager.QuoteExists(Quote{Content: content, SpeakerID: speaker.ID}) {
-		return Quote{
-			Content: "Sorry, but a quote with that content already exists for this user",
-		}
+	if speaker.OptOut {
+		return Quote{}, ErrOptedOut
+	}
+
+	status, ok := importStatus(status, speaker, submitter)
+	if !ok {
+		return Quote{}, ErrUnknownStatus
+	}
+
+	if manager.QuoteExists(Quote{Content: content, SpeakerID: speaker.ID, GuildID: guild.ID}) {
+		return duplicateQuote(), nil
 	}
 
 	quote := Quote{
@@ -142,9 +222,16 @@ func (manager Manager) AddLegacyQuote(content string, speaker User, submitter Us
 		Submitter:   submitter,
 		GuildID:     guild.ID,
 		Guild:       guild,
+		Status:      status,
 	}
-	manager.insertQuote(quote)
-	return quote
+	quote.CreatedAt = createdAt
+	added, err := manager.insertQuote(&quote)
+	if err != nil {
+		return Quote{}, err
+	} else if !added {
+		return duplicateQuote(), nil
+	}
+	return quote, nil
 }
 
 // GetRandomQuote - Chooses a random quote from a specific guild
@@ -163,7 +250,7 @@ func (manager Manager) GetNRandomQuotes(guildID string, amount int) []Quote {
 func (manager Manager) GetRandomQuoteBySpeaker(speakerID string, guildID string) Quote {
 	guildEntry := manager.FindGuild(guildID)
 	speakerEntry := manager.FindUser(speakerID, guildEntry.ID)
-	quotes := manager.FindManyQuotes(&Quote{SpeakerID: speakerEntry.ID, GuildID: guildEntry.ID})
+	quotes := manager.FindManyQuotes(&Quote{SpeakerID: speakerEntry.ID, GuildID: guildEntry.ID, Status: QuoteApproved})
 
 	return manager.chooseQuoteRandomly(quotes)
 }
@@ -171,11 +258,37 @@ func (manager Manager) GetRandomQuoteBySpeaker(speakerID string, guildID string)
 func (manager Manager) GetNRandomQuotesBySpeaker(speakerID string, guildID string, amount int) []Quote {
 	guildEntry := manager.FindGuild(guildID)
 	speakerEntry := manager.FindUser(speakerID, guildEntry.ID)
-	quotes := manager.FindManyQuotes(&Quote{SpeakerID: speakerEntry.ID, GuildID: guildEntry.ID})
+	quotes := manager.FindManyQuotes(&Quote{SpeakerID: speakerEntry.ID, GuildID: guildEntry.ID, Status: QuoteApproved})
 
 	return manager.chooseNRandomQuotes(quotes, amount)
 }
 
+// SearchQuotes - picks approved quotes of a guild whose content contains the text, ignoring case
+func (manager Manager) SearchQuotes(guildID string, text string, amount int) []Quote {
+	// struct conditions skip zero values, so an unknown guild would search the quotes of every guild
+	guildEntry := manager.FindGuildEntry(guildID)
+	if guildEntry.ID == 0 {
+		return []Quote{}
+	}
+	pattern := "%" + likeEscaper.Replace(strings.ToLower(text)) + "%"
+
+	var quotes []Quote
+	result := manager.Database.
+		Where("guild_id = ? AND status = ?", guildEntry.ID, QuoteApproved).
+		Where("LOWER(content) LIKE ?", pattern).
+		Preload(clause.Associations).
+		Preload("Guild.Settings").
+		Find(&quotes)
+	if result.Error != nil {
+		log.Println("Error searching quotes: ", result.Error)
+	}
+
+	return manager.chooseNRandomQuotes(quotes, amount)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, so searched text is matched literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
 // helper for random quotes
 func (manager Manager) chooseQuoteRandomly(quotes []Quote) Quote {
 	if len(quotes) > 0 {
@@ -209,12 +322,38 @@ func (manager Manager) chooseNRandomQuotes(quotes []Quote, amount int) []Quote {
 	return selectedQuotes
 }
 
+// QuoteExists - returns true if the speaker already has a quote with the same normalized content in the guild
+// deleted quotes count too, as they still hold their place in the unique index
 func (manager Manager) QuoteExists(query Quote) bool {
 	var existing Quote
-	result := manager.Database.Where(&query).First(&existing)
+	result := manager.Database.Unscoped().
+		Where(&Quote{ContentHash: ContentHash(query.Content), SpeakerID: query.SpeakerID, GuildID: query.GuildID}).
+		Limit(1).
+		Find(&existing)
 	return result.RowsAffected > 0
 }
 
+// FindSimilarQuote - finds a live quote of the same speaker in the same guild whose content is close to the query's
+func (manager Manager) FindSimilarQuote(query Quote) Quote {
+	var candidates []Quote
+	result := manager.Database.Select("id", "content").
+		Where(&Quote{SpeakerID: query.SpeakerID, GuildID: query.GuildID}).
+		Where("status IN ?", []QuoteStatus{QuoteApproved, QuotePending, QuoteConsent}).
+		Where("id <> ?", query.ID).
+		Find(&candidates)
+	if result.Error != nil {
+		log.Println("Error retrieving quotes: ", result.Error)
+		return Quote{}
+	}
+
+	for _, candidate := range candidates {
+		if SimilarContent(query.Content, candidate.Content) {
+			return manager.FindQuoteByID(candidate.ID)
+		}
+	}
+	return Quote{}
+}
+
 func (manager Manager) GuildExists(guild *discordgo.Guild) bool {
 	return manager.GuildExistsByID(guild.ID)
 }
@@ -248,10 +387,12 @@ func (manager Manager) UserExistsByName(userName string, guild Guild) bool {
 	return result.RowsAffected > 0
 }
 
-// InsertGuild adds a Guild to the database
-// move find and construction logic into separate event code or constructor
-func (manager Manager) insertGuild(guild Guild) {
-	result := manager.Database.Create(&guild)
+// InsertGuild upserts a Guild into the database, so concurrent events can't add it twice
+func (manager Manager) insertGuild(guild *Guild) {
+	result := manager.Database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "discord_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "left_at", "updated_at"}),
+	}).Create(guild)
 	if result.Error != nil {
 		log.Println("Error inserting guild: ", result.Error)
 		panic(result.Error)
@@ -260,26 +401,39 @@ func (manager Manager) insertGuild(guild Guild) {
 	log.Println("Guild Added: ", guild.Name, result.Name())
 }
 
-// InsertUser adds a User to the database
-// move find & construction logic into separate event code or constructor
-func (manager Manager) insertUser(user User) {
+// InsertUser adds a User to the database unless they are already stored
+// returns false if the user already existed, in which case user is left without an ID
+func (manager Manager) insertUser(user *User) bool {
 	// insert document into Database
-	result := manager.Database.Create(&user)
+	result := manager.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(user)
 	if result.Error != nil {
 		log.Println("Error inserting user: ", result.Error)
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
 	}
 	log.Println("User Added: ", user.Name, result.Name())
+	return true
 }
 
-// InsertQuote adds a Quote to the database
-// move find calls and construction into the event code or separate constructor
-func (manager Manager) insertQuote(quote Quote) {
-	//insert quote into DB
-	result := manager.Database.Create(&quote)
+// InsertQuote adds a Quote to the database unless the speaker already has it
+// returns false if the quote was not added, with the error if that wasn't because it already exists
+func (manager Manager) insertQuote(quote *Quote) (bool, error) {
+	//insert quote into DB, the speaker, submitter and guild are always stored already
+	result := manager.Database.
+		Omit(clause.Associations).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(quote)
 	if result.Error != nil {
 		log.Println("Error inserting quote: ", result.Error)
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
 	}
 	log.Printf("Quote Added: \"%s\" - %s, submitted by %s", quote.Content, quote.Speaker.Name, quote.Submitter.Name)
+	return true, nil
 }
 
 func (manager Manager) FindGuild(guildID string) Guild {
@@ -287,8 +441,10 @@ func (manager Manager) FindGuild(guildID string) Guild {
 	result := manager.Database.
 		Where(&Guild{DiscordID: guildID}).
 		Preload(clause.Associations).
+		Preload("Quotes", &Quote{Status: QuoteApproved}).
 		Preload("Quotes.Speaker").
 		Preload("Quotes.Submitter").
+		Preload("Quotes.Guild.Settings").
 		First(&guildEntry)
 
 	if result.Error != nil {
@@ -297,6 +453,31 @@ func (manager Manager) FindGuild(guildID string) Guild {
 	return guildEntry
 }
 
+// FindGuildMembers - finds a guild with its users and settings, without loading its quotes
+func (manager Manager) FindGuildMembers(guildID string) Guild {
+	var guildEntry Guild
+	result := manager.Database.
+		Where(&Guild{DiscordID: guildID}).
+		Preload("Users").
+		Preload("Settings").
+		Limit(1).
+		Find(&guildEntry)
+	if result.Error != nil {
+		log.Println(fmt.Sprintf("Error retrieving guild of ID %s: %s", guildID, result.Error))
+	}
+	return guildEntry
+}
+
+// FindGuildEntry - finds a guild without loading its users and quotes
+func (manager Manager) FindGuildEntry(guildID string) Guild {
+	var guildEntry Guild
+	result := manager.Database.Where(&Guild{DiscordID: guildID}).Limit(1).Find(&guildEntry)
+	if result.Error != nil {
+		log.Println(fmt.Sprintf("Error retrieving guild of ID %s: %s", guildID, result.Error))
+	}
+	return guildEntry
+}
+
 func (manager Manager) FindUser(userID string, guildID uint) User {
 	var userEntry User
 	result := manager.Database.Where(&User{DiscordID: userID, GuildID: guildID}).First(&userEntry)
@@ -315,11 +496,40 @@ func (manager Manager) FindUserByName(userName string, guildID uint) User {
 	return userEntry
 }
 
+// ResolveUser - finds a user of a guild by a name typed by someone, ignoring case
+// current names are preferred, then the most recently seen alias
+func (manager Manager) ResolveUser(name string, guildID uint) User {
+	var userEntry User
+	result := manager.Database.
+		Where("guild_id = ? AND (LOWER(name) = LOWER(?) OR LOWER(nickname) = LOWER(?) OR LOWER(global_name) = LOWER(?))",
+			guildID, name, name, name).
+		Limit(1).
+		Find(&userEntry)
+	if result.Error != nil {
+		log.Println(fmt.Sprintf("Error resolving user of Name %s: %s", name, result.Error))
+	}
+	if userEntry.ID != 0 {
+		return userEntry
+	}
+
+	result = manager.Database.
+		Joins("JOIN user_aliases ON user_aliases.user_id = users.id AND user_aliases.deleted_at IS NULL").
+		Where("users.guild_id = ? AND LOWER(user_aliases.alias) = LOWER(?)", guildID, name).
+		Order("user_aliases.updated_at DESC").
+		Limit(1).
+		Find(&userEntry)
+	if result.Error != nil {
+		log.Println(fmt.Sprintf("Error resolving user of alias %s: %s", name, result.Error))
+	}
+	return userEntry
+}
+
 func (manager Manager) FindQuote(query *Quote) Quote {
 	var quoteEntry Quote
 	result := manager.Database.
 		Where(&query).
 		Preload(clause.Associations).
+		Preload("Guild.Settings").
 		First(&quoteEntry)
 
 	if result.Error != nil {
@@ -328,11 +538,26 @@ func (manager Manager) FindQuote(query *Quote) Quote {
 	return quoteEntry
 }
 
+// FindQuoteByID - finds a quote by its database ID regardless of its status
+func (manager Manager) FindQuoteByID(quoteID uint) Quote {
+	var quoteEntry Quote
+	result := manager.Database.
+		Preload(clause.Associations).
+		Preload("Guild.Settings").
+		First(&quoteEntry, quoteID)
+
+	if result.Error != nil {
+		log.Println(fmt.Sprintf("Error retrieving quote of ID %d: %s", quoteID, result.Error))
+	}
+	return quoteEntry
+}
+
 func (manager Manager) FindManyQuotes(query *Quote) []Quote {
 	var quotes []Quote
 
 	result := manager.Database.Where(&query).
 		Preload(clause.Associations).
+		Preload("Guild.Settings").
 		Find(&quotes)
 
 	if result.Error != nil {
@@ -342,16 +567,214 @@ func (manager Manager) FindManyQuotes(query *Quote) []Quote {
 	return quotes
 }
 
+// UpdateGuild - refreshes the name of a guild, which also marks it as joined again if the bot had left
 func (manager Manager) UpdateGuild(discordGuild *discordgo.Guild) {
-	guild := manager.FindGuild(discordGuild.ID)
-	guild.DiscordID = discordGuild.ID
+	guild := manager.FindGuildEntry(discordGuild.ID)
 	guild.Name = discordGuild.Name
-	manager.Database.Save(&guild)
+	guild.LeftAt = nil
+	manager.Database.Model(&guild).Select("Name", "LeftAt").Updates(&guild)
 }
 
-func (manager Manager) UpdateGuildUser(discordUser *discordgo.User, guild Guild) {
-	user := manager.FindUser(discordUser.ID, guild.ID)
-	user.DiscordID = discordUser.ID
-	user.Name = discordUser.Username
-	manager.Database.Save(&user)
+// rows written per statement by the batched upserts
+const batchSize = 500
+
+// UpsertMembers - adds or refreshes many members of a guild at once, along with their aliases
+// returns the number of members written
+func (manager Manager) UpsertMembers(members []*discordgo.Member, guild Guild) int {
+	users := make([]User, 0, len(members))
+	memberByID := make(map[string]*discordgo.Member, len(members))
+	for _, member := range members {
+		if member.User == nil || memberByID[member.User.ID] != nil {
+			continue
+		}
+		memberByID[member.User.ID] = member
+		users = append(users, User{
+			Name:       member.User.Username,
+			GlobalName: member.User.GlobalName,
+			Nickname:   member.Nick,
+			AvatarHash: member.User.Avatar,
+			DiscordID:  member.User.ID,
+			GuildID:    guild.ID,
+		})
+	}
+	if len(users) == 0 {
+		return 0
+	}
+
+	result := manager.Database.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "discord_id"}, {Name: "guild_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"name", "global_name", "nickname", "avatar_hash", "departed_at", "updated_at"}),
+	}).CreateInBatches(&users, batchSize)
+	if result.Error != nil {
+		log.Println("Error upserting members: ", result.Error)
+		return 0
+	}
+
+	var aliases []UserAlias
+	for _, user := range users {
+		aliases = append(aliases, memberAliases(user, memberByID[user.DiscordID])...)
+	}
+	manager.recordAliases(aliases)
+
+	return len(users)
+}
+
+// SetQuoteStatus - moves a quote from one moderation state into another, returning the quote as it is afterwards
+// it also reports whether this call moved the quote, so stale or repeated answers leave a decided quote alone
+func (manager Manager) SetQuoteStatus(quoteID uint, from QuoteStatus, to QuoteStatus) (Quote, bool) {
+	result := manager.Database.Model(&Quote{}).
+		Where("id = ? AND status = ?", quoteID, from).
+		Update("status", to)
+	if result.Error != nil {
+		log.Println("Error updating quote status: ", result.Error)
+	}
+	return manager.FindQuoteByID(quoteID), result.RowsAffected > 0
+}
+
+// ResolveConsent - records the speaker's answer for a quote held for consent
+// an accepted quote continues on to the approval queue if the guild requires one
+// it also reports whether this call moved the quote out of consent, so concurrent answers only take effect once
+func (manager Manager) ResolveConsent(quoteID uint, accepted bool) (Quote, bool) {
+	quote := manager.FindQuoteByID(quoteID)
+	if quote.ID == 0 || quote.Status != QuoteConsent {
+		return quote, false
+	}
+
+	status := QuoteDeclined
+	if accepted && quote.Guild.Settings.RequireApproval {
+		status = QuotePending
+	} else if accepted {
+		status = QuoteApproved
+	}
+
+	return manager.SetQuoteStatus(quoteID, QuoteConsent, status)
+}
+
+// ResolveHeldQuote - records the submitter's answer for a quote held as a near-duplicate
+// a confirmed quote continues as a new submission would, a cancelled one is deleted and returned unchanged
+// it also reports whether this call moved the quote out of held, so concurrent answers only take effect once
+func (manager Manager) ResolveHeldQuote(quoteID uint, confirmed bool) (Quote, bool) {
+	quote := manager.FindQuoteByID(quoteID)
+	if quote.ID == 0 || quote.Status != QuoteHeld {
+		return quote, false
+	}
+
+	held := manager.Database.Model(&Quote{}).Where("id = ? AND status = ?", quoteID, QuoteHeld)
+	if !confirmed {
+		result := held.Unscoped().Delete(&Quote{})
+		if result.Error != nil {
+			log.Println("Error deleting held quote: ", result.Error)
+		}
+		return quote, result.RowsAffected > 0
+	}
+
+	status := submissionStatus(quote.Speaker, quote.Submitter, quote.Guild)
+	result := held.Update("status", status)
+	if result.Error != nil {
+		log.Println("Error updating quote status: ", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return manager.FindQuoteByID(quoteID), false
+	}
+	quote.Status = status
+	return quote, true
+}
+
+// PurgeHeldQuotes - deletes quotes held as near-duplicates that nobody confirmed before the given time
+func (manager Manager) PurgeHeldQuotes(before time.Time) int64 {
+	result := manager.Database.Unscoped().Where("status = ? AND created_at < ?", QuoteHeld, before).Delete(&Quote{})
+	if result.Error != nil {
+		log.Println("Error purging held quotes: ", result.Error)
+	}
+	return result.RowsAffected
+}
+
+// UpdateUserPrivacy - saves the quoting preferences of a user
+func (manager Manager) UpdateUserPrivacy(user User) {
+	result := manager.Database.Model(&user).Select("OptOut", "RequireConsent").Updates(&user)
+	if result.Error != nil {
+		log.Println("Error updating user privacy: ", result.Error)
+	}
+}
+
+// MarkUserDeparted - records that a user left a guild, their quotes stay attributed to them
+func (manager Manager) MarkUserDeparted(userID string, guild Guild) {
+	// struct conditions skip zero values, so an unknown guild or user would match far more than one row
+	if guild.ID == 0 || len(userID) == 0 {
+		return
+	}
+
+	result := manager.Database.Model(&User{}).
+		Where("discord_id = ? AND guild_id = ?", userID, guild.ID).
+		Update("departed_at", time.Now())
+	if result.Error != nil {
+		log.Println("Error marking user departed: ", result.Error)
+	}
+}
+
+// MarkGuildLeft - records that the bot was removed from a guild, starting its retention period
+func (manager Manager) MarkGuildLeft(guildID string) {
+	result := manager.Database.Model(&Guild{}).
+		Where(&Guild{DiscordID: guildID}).
+		Where("left_at IS NULL").
+		Update("left_at", time.Now())
+	if result.Error != nil {
+		log.Println("Error marking guild left: ", result.Error)
+	}
+}
+
+// PurgeGuild - permanently deletes a guild and everything stored about it
+func (manager Manager) PurgeGuild(guild Guild) error {
+	return manager.Database.Transaction(func(tx *gorm.DB) error {
+		userIDs := tx.Unscoped().Model(&User{}).Select("id").Where(&User{GuildID: guild.ID})
+		jobIDs := tx.Unscoped().Model(&MigrationJob{}).Select("id").Where(&MigrationJob{GuildDiscordID: guild.DiscordID})
+
+		// children first, so no foreign key is left dangling
+		if err := tx.Unscoped().Where("user_id IN (?)", userIDs).Delete(&UserAlias{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(&Quote{GuildID: guild.ID}).Delete(&Quote{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(&User{GuildID: guild.ID}).Delete(&User{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("migration_job_id IN (?)", jobIDs).Delete(&MigrationNameMap{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(&MigrationJob{GuildDiscordID: guild.DiscordID}).Delete(&MigrationJob{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(&RolePermission{GuildID: guild.ID}).Delete(&RolePermission{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(&ChannelRule{GuildID: guild.ID}).Delete(&ChannelRule{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where(&GuildSettings{GuildID: guild.ID}).Delete(&GuildSettings{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&guild).Error
+	})
+}
+
+// PurgeLeftGuilds - purges every guild the bot left before the given time, returning how many were purged
+func (manager Manager) PurgeLeftGuilds(before time.Time) int {
+	var guilds []Guild
+	result := manager.Database.Where("left_at IS NOT NULL AND left_at < ?", before).Find(&guilds)
+	if result.Error != nil {
+		log.Println("Error retrieving left guilds: ", result.Error)
+		return 0
+	}
+
+	purged := 0
+	for _, guild := range guilds {
+		if err := manager.PurgeGuild(guild); err != nil {
+			log.Printf("Error purging guild %s: %v", guild.Name, err)
+			continue
+		}
+		log.Println("Guild Purged: ", guild.Name)
+		purged++
+	}
+	return purged
 }
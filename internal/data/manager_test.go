@@ -0,0 +1,89 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestSetQuoteStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		from        QuoteStatus
+		to          QuoteStatus
+		stored      QuoteStatus // status of the quote in the database before the call
+		wantMoved   bool
+		wantStatus  QuoteStatus
+		wantMissing bool
+	}{
+		{name: "pending approved", from: QuotePending, to: QuoteApproved, stored: QuotePending, wantMoved: true, wantStatus: QuoteApproved},
+		{name: "pending rejected", from: QuotePending, to: QuoteRejected, stored: QuotePending, wantMoved: true, wantStatus: QuoteRejected},
+		{name: "already approved", from: QuotePending, to: QuoteRejected, stored: QuoteApproved, wantStatus: QuoteApproved},
+		{name: "already rejected", from: QuotePending, to: QuoteApproved, stored: QuoteRejected, wantStatus: QuoteRejected},
+		{name: "waiting on consent", from: QuotePending, to: QuoteApproved, stored: QuoteConsent, wantStatus: QuoteConsent},
+		{name: "held", from: QuotePending, to: QuoteApproved, stored: QuoteHeld, wantStatus: QuoteHeld},
+		{name: "declined", from: QuotePending, to: QuoteApproved, stored: QuoteDeclined, wantStatus: QuoteDeclined},
+		{name: "consent accepted", from: QuoteConsent, to: QuoteApproved, stored: QuoteConsent, wantMoved: true, wantStatus: QuoteApproved},
+		{name: "deleted quote", from: QuotePending, to: QuoteApproved, wantMissing: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stored := test.stored
+			manager, database := newFakeManager(t, func(statement fakeStatement) fakeAnswer {
+				switch {
+				case statement.mentions(`UPDATE "quotes"`, "status = $"):
+					// the update only matches a quote still in the expected status, which is its last argument
+					if len(stored) > 0 && statement.args[len(statement.args)-1] == string(stored) {
+						stored = test.to
+						return fakeAnswer{affected: 1}
+					}
+				case statement.mentions(`FROM "quotes"`) && len(stored) > 0:
+					return fakeAnswer{columns: []string{"id", "status"}, rows: [][]driver.Value{{int64(7), string(stored)}}}
+				}
+				return fakeAnswer{}
+			})
+
+			quote, moved := manager.SetQuoteStatus(7, test.from, test.to)
+			if moved != test.wantMoved {
+				t.Errorf("SetQuoteStatus moved = %v, want %v", moved, test.wantMoved)
+			}
+			if test.wantMissing {
+				if quote.ID != 0 {
+					t.Errorf("SetQuoteStatus = %+v, want no quote", quote)
+				}
+				return
+			}
+			if quote.ID != 7 || quote.Status != test.wantStatus {
+				t.Errorf("SetQuoteStatus = quote %d %q, want quote 7 %q", quote.ID, quote.Status, test.wantStatus)
+			}
+
+			updates := database.sent(`UPDATE "quotes"`)
+			if len(updates) != 1 || !updates[0].has(string(test.from)) || !updates[0].has(int64(7)) {
+				t.Errorf("SetQuoteStatus sent %+v, want one update conditional on the id and %q", updates, test.from)
+			}
+		})
+	}
+}
+
+func TestFindGuildMembers(t *testing.T) {
+	manager, database := newFakeManager(t, func(statement fakeStatement) fakeAnswer {
+		switch {
+		case statement.mentions(`FROM "guilds"`):
+			return fakeAnswer{columns: []string{"id", "discord_id"}, rows: [][]driver.Value{{int64(3), "guild"}}}
+		case statement.mentions(`FROM "users"`):
+			return fakeAnswer{columns: []string{"id", "guild_id", "name"}, rows: [][]driver.Value{{int64(5), int64(3), "speaker"}}}
+		}
+		return fakeAnswer{}
+	})
+
+	guild := manager.FindGuildMembers("guild")
+	if guild.ID != 3 || len(guild.Users) != 1 || guild.Users[0].Name != "speaker" {
+		t.Errorf("FindGuildMembers = guild %d with users %+v, want guild 3 with the speaker", guild.ID, guild.Users)
+	}
+	if quotes := database.sent(`FROM "quotes"`); len(quotes) > 0 {
+		t.Errorf("FindGuildMembers loaded quotes with %q", quotes[0].query)
+	}
+	if settings := database.sent(`FROM "guild_settings"`); len(settings) != 1 {
+		t.Errorf("FindGuildMembers sent %d settings queries, want 1", len(settings))
+	}
+}
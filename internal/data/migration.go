@@ -0,0 +1,92 @@
+package data
+
+import (
+	"gorm.io/gorm"
+	"log"
+)
+
+// MigrationStatus - the state of a MigrationJob
+type MigrationStatus string
+
+const (
+	MigrationPending MigrationStatus = "pending" // waiting for the guild to come online
+	MigrationDone    MigrationStatus = "done"    // the legacy quotes have been imported
+	MigrationFailed  MigrationStatus = "failed"  // the import stopped, see MigrationJob.Error
+)
+
+// MigrationJob - an import of legacy QuoteBot quotes into a single guild
+type MigrationJob struct {
+	gorm.Model
+	GuildDiscordID string             `gorm:"uniqueIndex"` // Discord ID of the guild the quotes belong to
+	QuotesFile     string             // path of the legacy quotes file
+	SubmitterName  string             // name of the User legacy quotes are submitted by
+	Status         MigrationStatus    `gorm:"default:pending"` // state of the job
+	Error          string             // why the job failed, if it did
+	Imported       int                // quotes that were added
+	Duplicates     int                // quotes skipped because they already existed
+	Empty          int                // quotes skipped because they had no content
+	Unmatched      int                // quotes skipped because their speaker is unknown
+	OptedOut       int                // quotes skipped because their speaker asked not to be quoted
+	Failed         int                // quotes that couldn't be stored
+	NameMaps       []MigrationNameMap // how legacy speaker names map to guild members
+}
+
+// MigrationNameMap - maps a legacy speaker name to a guild member
+type MigrationNameMap struct {
+	gorm.Model
+	MigrationJobID uint   // database ID of the job this mapping belongs to
+	OldName        string // the speaker name used by the legacy bot
+	UserName       string // the name of the User it maps to
+	UserDiscordID  string // the Discord ID of the User it maps to, if it was mapped to a member directly
+}
+
+// FindMigrationJob - finds the migration job of a guild, the returned job has no ID if there is none
+func (manager Manager) FindMigrationJob(guildID string) MigrationJob {
+	var job MigrationJob
+	result := manager.Database.
+		Where(&MigrationJob{GuildDiscordID: guildID}).
+		Preload("NameMaps").
+		Limit(1).
+		Find(&job)
+
+	if result.Error != nil {
+		log.Println("Error retrieving migration job of guild ", guildID, result.Error)
+	}
+	return job
+}
+
+// ListMigrationJobs - all migration jobs, oldest first
+func (manager Manager) ListMigrationJobs() []MigrationJob {
+	var jobs []MigrationJob
+	result := manager.Database.Preload("NameMaps").Order("created_at").Find(&jobs)
+	if result.Error != nil {
+		log.Println("Error retrieving migration jobs: ", result.Error)
+	}
+	return jobs
+}
+
+// SaveMigrationJob - inserts or updates a migration job along with its name maps
+func (manager Manager) SaveMigrationJob(job *MigrationJob) {
+	result := manager.Database.Session(&gorm.Session{FullSaveAssociations: true}).Save(job)
+	if result.Error != nil {
+		log.Println("Error saving migration job: ", result.Error)
+	}
+}
+
+// SetMigrationNameMap - maps a legacy speaker name of a job to a guild member, replacing any earlier mapping
+func (manager Manager) SetMigrationNameMap(job MigrationJob, oldName string, user User) {
+	nameMap := MigrationNameMap{
+		MigrationJobID: job.ID,
+		OldName:        oldName,
+		UserName:       user.Name,
+		UserDiscordID:  user.DiscordID,
+	}
+
+	result := manager.Database.
+		Where(&MigrationNameMap{MigrationJobID: job.ID, OldName: oldName}).
+		Assign(&nameMap).
+		FirstOrCreate(&nameMap)
+	if result.Error != nil {
+		log.Println("Error saving migration name map: ", result.Error)
+	}
+}
@@ -0,0 +1,76 @@
+package data
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+)
+
+// Capability - something a member may be allowed to do with the quotes of a guild
+type Capability string
+
+const (
+	AddCapability       Capability = "add"        // add quotes, when the guild limits who may
+	DeleteAnyCapability Capability = "delete-any" // remove quotes submitted by anyone, such as rejecting pending quotes
+	EditAnyCapability   Capability = "edit-any"   // change quotes submitted by anyone, such as approving pending quotes
+	ExportCapability    Capability = "export"     // download every quote of the guild
+	ConfigureCapability Capability = "configure"  // change how QuoteBot behaves in the guild
+)
+
+// Capabilities - every capability a role can be granted
+var Capabilities = []Capability{AddCapability, DeleteAnyCapability, EditAnyCapability, ExportCapability, ConfigureCapability}
+
+// RolePermission - a capability granted to a Discord role of a guild
+type RolePermission struct {
+	gorm.Model
+	GuildID    uint       `gorm:"uniqueIndex:idx_role_capability"` // the ID of the Guild the role belongs to
+	RoleID     string     `gorm:"uniqueIndex:idx_role_capability"` // Discord ID of the role
+	Capability Capability `gorm:"uniqueIndex:idx_role_capability"` // what members with the role may do
+}
+
+// GrantCapability - allows members with a role to do something, reporting whether the role did not have it yet
+func (manager Manager) GrantCapability(guild Guild, roleID string, capability Capability) bool {
+	permission := RolePermission{GuildID: guild.ID, RoleID: roleID, Capability: capability}
+	result := manager.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(&permission)
+	if result.Error != nil {
+		log.Println("Error granting capability: ", result.Error)
+	}
+	return result.RowsAffected > 0
+}
+
+// RevokeCapability - takes a capability away from a role, reporting whether the role had it
+func (manager Manager) RevokeCapability(guild Guild, roleID string, capability Capability) bool {
+	result := manager.Database.Unscoped().
+		Where(&RolePermission{GuildID: guild.ID, RoleID: roleID, Capability: capability}).
+		Delete(&RolePermission{})
+	if result.Error != nil {
+		log.Println("Error revoking capability: ", result.Error)
+	}
+	return result.RowsAffected > 0
+}
+
+// FindRolePermissions - every capability granted to the roles of a guild, grouped by role
+func (manager Manager) FindRolePermissions(guild Guild) []RolePermission {
+	var permissions []RolePermission
+	result := manager.Database.Where(&RolePermission{GuildID: guild.ID}).Order("role_id, capability").Find(&permissions)
+	if result.Error != nil {
+		log.Println("Error retrieving role permissions: ", result.Error)
+	}
+	return permissions
+}
+
+// RolesHaveCapability - reports whether any of the given roles of a guild has been granted a capability
+func (manager Manager) RolesHaveCapability(guild Guild, roleIDs []string, capability Capability) bool {
+	if len(roleIDs) == 0 {
+		return false
+	}
+
+	var count int64
+	result := manager.Database.Model(&RolePermission{}).
+		Where("guild_id = ? AND capability = ? AND role_id IN ?", guild.ID, capability, roleIDs).
+		Count(&count)
+	if result.Error != nil {
+		log.Println("Error checking role permissions: ", result.Error)
+	}
+	return count > 0
+}
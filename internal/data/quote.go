@@ -1,15 +1,56 @@
 package data
 
-import "gorm.io/gorm"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// QuoteStatus - the moderation state of a Quote
+type QuoteStatus string
+
+const (
+	QuoteApproved QuoteStatus = "approved" // visible to every retrieval path
+	QuotePending  QuoteStatus = "pending"  // waiting on a moderator decision
+	QuoteRejected QuoteStatus = "rejected" // declined by a moderator
+	QuoteConsent  QuoteStatus = "consent"  // waiting on the speaker to consent
+	QuoteDeclined QuoteStatus = "declined" // the speaker refused to be quoted
+	QuoteHeld     QuoteStatus = "held"     // similar to an existing quote, waiting on the submitter to confirm it
+)
+
+// QuoteStatuses - every status a Quote can have
+var QuoteStatuses = []QuoteStatus{QuoteApproved, QuotePending, QuoteRejected, QuoteConsent, QuoteDeclined, QuoteHeld}
 
 // Quote - Object representing a quote
 type Quote struct {
 	gorm.Model
-	Content     string // The content of the quote
-	SpeakerID   uint   // The ID of the one who spoke the Quote
-	Speaker     User   // The User that spoke the Quote
-	SubmitterID uint   // The ID of the one who submitted the Quote
-	Submitter   User   // The User that submitted the Quote
-	GuildID     uint   // the ID of the Guild the quote was posted in
-	Guild       Guild  // The Guild the Quote was posted in
+	Content     string      // The content of the quote
+	SpeakerID   uint        `gorm:"uniqueIndex:idx_quote_content"` // The ID of the one who spoke the Quote
+	Speaker     User        // The User that spoke the Quote
+	SubmitterID uint        // The ID of the one who submitted the Quote
+	Submitter   User        // The User that submitted the Quote
+	GuildID     uint        `gorm:"uniqueIndex:idx_quote_content"` // the ID of the Guild the quote was posted in
+	Guild       Guild       // The Guild the Quote was posted in
+	Status      QuoteStatus `gorm:"default:approved;index"`        // moderation state of the Quote
+	ContentHash string      `gorm:"uniqueIndex:idx_quote_content"` // hash of the normalized Content, unique per speaker per guild
+}
+
+// NormalizeContent - the form of a quote's content used to recognize the same quote
+func NormalizeContent(content string) string {
+	return strings.Join(strings.Fields(strings.ToLower(content)), " ")
+}
+
+// ContentHash - the hash of a quote's normalized content
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(NormalizeContent(content)))
+	return hex.EncodeToString(sum[:])
+}
+
+// BeforeSave - keeps the content hash in line with the content
+func (quote *Quote) BeforeSave(*gorm.DB) error {
+	if len(quote.Content) > 0 {
+		quote.ContentHash = ContentHash(quote.Content)
+	}
+	return nil
 }
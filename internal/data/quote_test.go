@@ -0,0 +1,60 @@
+package data
+
+import (
+	"gorm.io/gorm"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	tests := []struct {
+		first  string
+		second string
+		same   bool
+	}{
+		{first: "Hello there", second: "hello   THERE", same: true},
+		{first: "Hello there", second: " Hello\nthere\t", same: true},
+		{first: "Hello there", second: "Hello there!", same: false},
+		{first: "Hello there", second: "Hello, there", same: false},
+	}
+
+	for _, test := range tests {
+		if same := ContentHash(test.first) == ContentHash(test.second); same != test.same {
+			t.Errorf("ContentHash(%q) == ContentHash(%q) is %v, want %v", test.first, test.second, same, test.same)
+		}
+	}
+}
+
+func TestImportStatus(t *testing.T) {
+	speaker := User{Model: gorm.Model{ID: 1}}
+	consenting := User{Model: gorm.Model{ID: 1}, RequireConsent: true}
+	submitter := User{Model: gorm.Model{ID: 2}}
+
+	tests := []struct {
+		name      string
+		exported  QuoteStatus
+		speaker   User
+		submitter User
+		want      QuoteStatus
+		wantOK    bool
+	}{
+		{name: "no status", exported: "", speaker: speaker, submitter: submitter, want: QuoteApproved, wantOK: true},
+		{name: "approved", exported: QuoteApproved, speaker: speaker, submitter: submitter, want: QuoteApproved, wantOK: true},
+		{name: "pending", exported: QuotePending, speaker: speaker, submitter: submitter, want: QuotePending, wantOK: true},
+		{name: "rejected", exported: QuoteRejected, speaker: speaker, submitter: submitter, want: QuoteRejected, wantOK: true},
+		{name: "held", exported: QuoteHeld, speaker: speaker, submitter: submitter, want: QuotePending, wantOK: true},
+		{name: "held needing consent", exported: QuoteHeld, speaker: consenting, submitter: submitter, want: QuotePending, wantOK: true},
+		{name: "approved needing consent", exported: QuoteApproved, speaker: consenting, submitter: submitter, want: QuoteConsent, wantOK: true},
+		{name: "no status needing consent", exported: "", speaker: consenting, submitter: submitter, want: QuoteConsent, wantOK: true},
+		{name: "submitted by the speaker", exported: QuoteApproved, speaker: consenting, submitter: consenting, want: QuoteApproved, wantOK: true},
+		{name: "pending needing consent", exported: QuotePending, speaker: consenting, submitter: submitter, want: QuotePending, wantOK: true},
+		{name: "declined needing consent", exported: QuoteDeclined, speaker: consenting, submitter: submitter, want: QuoteDeclined, wantOK: true},
+		{name: "unknown", exported: "archived", speaker: speaker, submitter: submitter, wantOK: false},
+	}
+
+	for _, test := range tests {
+		got, ok := importStatus(test.exported, test.speaker, test.submitter)
+		if got != test.want || ok != test.wantOK {
+			t.Errorf("%s: importStatus = %q, %v, want %q, %v", test.name, got, ok, test.want, test.wantOK)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package data
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"log"
+)
+
+// every model stored in the Database
+var models = []interface{}{
+	&Guild{},
+	&User{},
+	&Quote{},
+	&MigrationJob{},
+	&MigrationNameMap{},
+	&UserAlias{},
+	&GuildSettings{},
+	&RolePermission{},
+	&ChannelRule{},
+}
+
+// migrateSchema brings the Database up to date with the models
+// rows that would break the unique indexes are merged away before the indexes are created
+func migrateSchema(db *gorm.DB) error {
+	if err := prepareUniqueIndexes(db); err != nil {
+		return fmt.Errorf("preparing unique indexes: %w", err)
+	}
+
+	// the legacy commands and name display used to be stored on the guild itself, apart from its other settings
+	movePreferences := db.Migrator().HasTable(&Guild{}) &&
+		(db.Migrator().HasColumn(&Guild{}, "legacy_commands") || db.Migrator().HasColumn(&Guild{}, "name_display"))
+
+	if err := db.AutoMigrate(models...); err != nil {
+		return err
+	}
+
+	if movePreferences {
+		if err := movePreferencesToSettings(db); err != nil {
+			return fmt.Errorf("moving guild preferences to guild settings: %w", err)
+		}
+	}
+	return nil
+}
+
+// movePreferencesToSettings copies the legacy commands and name display of every guild to its settings and drops them from guilds
+func movePreferencesToSettings(db *gorm.DB) error {
+	moves := []struct{ column, statement string }{
+		{"legacy_commands", `INSERT INTO guild_settings (created_at, updated_at, guild_id, legacy_commands)
+			SELECT NOW(), NOW(), id, legacy_commands FROM guilds WHERE legacy_commands
+			ON CONFLICT (guild_id) DO UPDATE SET legacy_commands = EXCLUDED.legacy_commands`},
+		{"name_display", `INSERT INTO guild_settings (created_at, updated_at, guild_id, name_display)
+			SELECT NOW(), NOW(), id, name_display FROM guilds WHERE name_display IS NOT NULL AND name_display <> ''
+			ON CONFLICT (guild_id) DO UPDATE SET name_display = EXCLUDED.name_display`},
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, move := range moves {
+			if !tx.Migrator().HasColumn(&Guild{}, move.column) {
+				continue
+			}
+
+			result := tx.Exec(move.statement)
+			if result.Error != nil {
+				return result.Error
+			}
+			log.Printf("Moved the %s of %d guilds to their settings", move.column, result.RowsAffected)
+
+			if err := tx.Migrator().DropColumn(&Guild{}, move.column); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ranked numbers each row against the first row with the same key, which is the one that is kept
+const ranked = `WITH ranked AS (SELECT id, FIRST_VALUE(id) OVER (PARTITION BY %s ORDER BY id) AS keep FROM %s) `
+
+func prepareUniqueIndexes(db *gorm.DB) error {
+	migrator := db.Migrator()
+
+	if migrator.HasTable(&Guild{}) && !migrator.HasIndex(&Guild{}, "idx_guilds_discord_id") {
+		if err := mergeDuplicateGuilds(db); err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasTable(&User{}) && !migrator.HasIndex(&User{}, "idx_user_guild") {
+		if err := mergeDuplicateUsers(db); err != nil {
+			return err
+		}
+	}
+
+	if migrator.HasTable(&Quote{}) && !migrator.HasIndex(&Quote{}, "idx_quote_content") {
+		if err := removeDuplicateQuotes(db); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDuplicateGuilds moves users and quotes of repeated guilds to the first entry of the guild
+func mergeDuplicateGuilds(db *gorm.DB) error {
+	guilds := fmt.Sprintf(ranked, "discord_id", "guilds")
+	statements := []string{
+		guilds + `UPDATE users SET guild_id = ranked.keep FROM ranked WHERE users.guild_id = ranked.id AND ranked.id <> ranked.keep`,
+		guilds + `UPDATE quotes SET guild_id = ranked.keep FROM ranked WHERE quotes.guild_id = ranked.id AND ranked.id <> ranked.keep`,
+		guilds + `DELETE FROM guilds USING ranked WHERE guilds.id = ranked.id AND ranked.id <> ranked.keep`,
+	}
+	return runStatements(db, "guilds", statements)
+}
+
+// mergeDuplicateUsers attributes the quotes of repeated users to the first entry of the user
+// aliases of the repeated entries are dropped, member sync records them again
+func mergeDuplicateUsers(db *gorm.DB) error {
+	users := fmt.Sprintf(ranked, "discord_id, guild_id", "users")
+	statements := []string{
+		users + `UPDATE quotes SET speaker_id = ranked.keep FROM ranked WHERE quotes.speaker_id = ranked.id AND ranked.id <> ranked.keep`,
+		users + `UPDATE quotes SET submitter_id = ranked.keep FROM ranked WHERE quotes.submitter_id = ranked.id AND ranked.id <> ranked.keep`,
+	}
+	if db.Migrator().HasTable(&UserAlias{}) {
+		statements = append(statements,
+			users+`DELETE FROM user_aliases USING ranked WHERE user_aliases.user_id = ranked.id AND ranked.id <> ranked.keep`)
+	}
+	statements = append(statements,
+		users+`DELETE FROM users USING ranked WHERE users.id = ranked.id AND ranked.id <> ranked.keep`)
+	return runStatements(db, "users", statements)
+}
+
+// removeDuplicateQuotes fills in the content hash of every quote and deletes all but the first copy of a quote
+func removeDuplicateQuotes(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Quote{}, "ContentHash") {
+		if err := db.Migrator().AddColumn(&Quote{}, "ContentHash"); err != nil {
+			return err
+		}
+	}
+
+	var quotes []Quote
+	result := db.Unscoped().Select("id", "content").Where("content_hash IS NULL OR content_hash = ''").
+		FindInBatches(&quotes, batchSize, func(tx *gorm.DB, batch int) error {
+			for _, quote := range quotes {
+				err := tx.Unscoped().Model(&quote).UpdateColumn("content_hash", ContentHash(quote.Content)).Error
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	statements := []string{
+		fmt.Sprintf(ranked, "guild_id, speaker_id, content_hash", "quotes") +
+			`DELETE FROM quotes USING ranked WHERE quotes.id = ranked.id AND ranked.id <> ranked.keep`,
+	}
+	return runStatements(db, "quotes", statements)
+}
+
+func runStatements(db *gorm.DB, table string, statements []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			result := tx.Exec(statement)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected > 0 {
+				log.Printf("Merged duplicate %s: %d rows affected", table, result.RowsAffected)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,52 @@
+package data
+
+import (
+	"database/sql/driver"
+	"testing"
+)
+
+func TestMovePreferencesToSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		columns   []string // columns guilds still has before the move
+		wantMoved []string
+	}{
+		{name: "both columns", columns: []string{"legacy_commands", "name_display"}, wantMoved: []string{"legacy_commands", "name_display"}},
+		{name: "only legacy commands", columns: []string{"legacy_commands"}, wantMoved: []string{"legacy_commands"}},
+		{name: "only name display", columns: []string{"name_display"}, wantMoved: []string{"name_display"}},
+		{name: "already moved"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager, database := newFakeManager(t, func(statement fakeStatement) fakeAnswer {
+				if statement.mentions("INFORMATION_SCHEMA.columns") {
+					count := int64(0)
+					for _, column := range test.columns {
+						if statement.has(column) {
+							count = 1
+						}
+					}
+					return fakeAnswer{columns: []string{"count"}, rows: [][]driver.Value{{count}}}
+				}
+				return fakeAnswer{}
+			})
+
+			if err := movePreferencesToSettings(manager.Database); err != nil {
+				t.Fatalf("movePreferencesToSettings() error = %v", err)
+			}
+
+			copies := database.sent("INSERT INTO guild_settings")
+			drops := database.sent(`ALTER TABLE "guilds" DROP COLUMN`)
+			if len(copies) != len(test.wantMoved) || len(drops) != len(test.wantMoved) {
+				t.Fatalf("movePreferencesToSettings sent %d copies and %d drops, want %d of each",
+					len(copies), len(drops), len(test.wantMoved))
+			}
+			for index, column := range test.wantMoved {
+				if !copies[index].mentions("SET "+column+" = EXCLUDED."+column) || !drops[index].mentions(`"`+column+`"`) {
+					t.Errorf("move %d = %q then %q, want %s copied then dropped", index, copies[index].query, drops[index].query, column)
+				}
+			}
+		})
+	}
+}
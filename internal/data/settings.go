@@ -0,0 +1,180 @@
+package data
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+)
+
+// the amount of quotes a single request may return when a guild has not chosen one
+const DefaultMaxAmount = 10
+
+// the most quotes a guild may allow in a single request, which is also the most embeds a message can hold
+const MaxAmountLimit = 10
+
+// EmbedStyle - how a guild's quotes are rendered
+type EmbedStyle string
+
+const (
+	FullEmbed    EmbedStyle = "full"    // the speaker's avatar and colour, the submitter and the date
+	CompactEmbed EmbedStyle = "compact" // only the quote and its speaker
+)
+
+// EmbedStyles - every style a guild can choose
+var EmbedStyles = []EmbedStyle{FullEmbed, CompactEmbed}
+
+// AddPermission - who may add quotes to a guild
+type AddPermission string
+
+const (
+	EveryoneMayAdd   AddPermission = "everyone"   // every member
+	ModeratorsMayAdd AddPermission = "moderators" // members who can manage messages or have a role granted AddCapability
+)
+
+// AddPermissions - every choice of who may add quotes
+var AddPermissions = []AddPermission{EveryoneMayAdd, ModeratorsMayAdd}
+
+// NameDisplay - which of a user's names a guild shows on quotes
+type NameDisplay string
+
+const (
+	NicknameDisplay   NameDisplay = "nickname"     // the guild nickname, as members see it in the server
+	GlobalNameDisplay NameDisplay = "display-name" // the display name chosen for the account
+	UsernameDisplay   NameDisplay = "username"     // the unique account handle
+)
+
+// GuildSettings - the preferences of a single guild, the zero value of every field is the default
+type GuildSettings struct {
+	gorm.Model
+	GuildID           uint          `gorm:"uniqueIndex"` // the ID of the Guild the settings belong to
+	MaxAmount         int           // the most quotes a single request returns, DefaultMaxAmount if 0
+	MuteAnnouncements bool          // whether the bot stays quiet when it starts in the guild
+	AnnounceChannelID string        // Discord ID of the channel announcements are sent to, the system channel if empty
+	EmbedStyle        EmbedStyle    // how quotes are rendered, FullEmbed if empty
+	RequireApproval   bool          // whether new quotes must be approved by a moderator
+	ApprovalChannelID string        // Discord ID of the channel pending quotes are posted to
+	AddPermission     AddPermission // who may add quotes, EveryoneMayAdd if empty
+	UserRateLimit     string        // how often a member may use quote commands, like "5/30s" or "off"; the bot's default if empty
+	ChannelRateLimit  string        // how often quote commands may be used in a channel; the bot's default if empty
+	GuildRateLimit    string        // how often quote commands may be used in the guild; the bot's default if empty
+	OutsideChannels   OutsidePolicy // how quote commands are answered outside the allowed channels, EphemeralOutside if empty
+	RedirectChannelID string        // Discord ID of the channel redirected answers go to, the first allowed channel if empty
+	NameDisplay       NameDisplay   // which user name quotes are shown with, NicknameDisplay if empty
+	LegacyCommands    bool          // whether the legacy !quote prefix commands are answered
+}
+
+// RateLimitScope - what a rate limit is counted per
+type RateLimitScope string
+
+const (
+	UserRateLimit    RateLimitScope = "user"
+	ChannelRateLimit RateLimitScope = "channel"
+	GuildRateLimit   RateLimitScope = "guild"
+)
+
+// RateLimitScopes - every scope a rate limit can be counted per
+var RateLimitScopes = []RateLimitScope{UserRateLimit, ChannelRateLimit, GuildRateLimit}
+
+// RateLimit - the override of the rate limit of a scope, empty if the guild uses the bot's default
+func (settings GuildSettings) RateLimit(scope RateLimitScope) string {
+	switch scope {
+	case UserRateLimit:
+		return settings.UserRateLimit
+	case ChannelRateLimit:
+		return settings.ChannelRateLimit
+	case GuildRateLimit:
+		return settings.GuildRateLimit
+	}
+	return ""
+}
+
+// SetRateLimit - overrides the rate limit of a scope, an empty rule goes back to the bot's default
+func (settings *GuildSettings) SetRateLimit(scope RateLimitScope, rule string) {
+	switch scope {
+	case UserRateLimit:
+		settings.UserRateLimit = rule
+	case ChannelRateLimit:
+		settings.ChannelRateLimit = rule
+	case GuildRateLimit:
+		settings.GuildRateLimit = rule
+	}
+}
+
+// QuoteLimit - the most quotes a single request returns
+func (settings GuildSettings) QuoteLimit() int {
+	if settings.MaxAmount <= 0 {
+		return DefaultMaxAmount
+	}
+	return settings.MaxAmount
+}
+
+// Style - how quotes are rendered
+func (settings GuildSettings) Style() EmbedStyle {
+	if len(settings.EmbedStyle) == 0 {
+		return FullEmbed
+	}
+	return settings.EmbedStyle
+}
+
+// WhoMayAdd - who may add quotes
+func (settings GuildSettings) WhoMayAdd() AddPermission {
+	if len(settings.AddPermission) == 0 {
+		return EveryoneMayAdd
+	}
+	return settings.AddPermission
+}
+
+// Names - which user name quotes are shown with
+func (settings GuildSettings) Names() NameDisplay {
+	if len(settings.NameDisplay) == 0 {
+		return NicknameDisplay
+	}
+	return settings.NameDisplay
+}
+
+// Outside - how quote commands are answered outside the allowed channels
+func (settings GuildSettings) Outside() OutsidePolicy {
+	if len(settings.OutsideChannels) == 0 {
+		return EphemeralOutside
+	}
+	return settings.OutsideChannels
+}
+
+// AnnouncementChannel - the channel the bot announces itself in, empty if announcements are muted or there is nowhere to send them
+func (settings GuildSettings) AnnouncementChannel(guild *discordgo.Guild) string {
+	if settings.MuteAnnouncements {
+		return ""
+	} else if len(settings.AnnounceChannelID) > 0 {
+		return settings.AnnounceChannelID
+	}
+	return guild.SystemChannelID
+}
+
+// FindGuildSettings - the settings of a guild, created with the defaults if it has none yet
+func (manager Manager) FindGuildSettings(guild Guild) GuildSettings {
+	settings := GuildSettings{GuildID: guild.ID}
+	result := manager.Database.Clauses(clause.OnConflict{DoNothing: true}).Create(&settings)
+	if result.Error != nil {
+		log.Println("Error creating guild settings: ", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return settings
+	}
+
+	result = manager.Database.Where(&GuildSettings{GuildID: guild.ID}).First(&settings)
+	if result.Error != nil {
+		log.Println(fmt.Sprintf("Error retrieving settings of guild %s: %s", guild.Name, result.Error))
+	}
+	return settings
+}
+
+// SaveGuildSettings - stores every field of a guild's settings
+func (manager Manager) SaveGuildSettings(settings GuildSettings) GuildSettings {
+	result := manager.Database.Save(&settings)
+	if result.Error != nil {
+		log.Println("Error saving guild settings: ", result.Error)
+	}
+	return settings
+}
@@ -0,0 +1,21 @@
+package data
+
+import "testing"
+
+func TestNames(t *testing.T) {
+	tests := []struct {
+		display NameDisplay
+		want    NameDisplay
+	}{
+		{display: "", want: NicknameDisplay},
+		{display: NicknameDisplay, want: NicknameDisplay},
+		{display: GlobalNameDisplay, want: GlobalNameDisplay},
+		{display: UsernameDisplay, want: UsernameDisplay},
+	}
+
+	for _, test := range tests {
+		if got := (GuildSettings{NameDisplay: test.display}).Names(); got != test.want {
+			t.Errorf("Names() with %q = %q, want %q", test.display, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package data
+
+import (
+	"strings"
+	"unicode"
+)
+
+// similarityRatio - one edit is tolerated for every this many characters of a quote
+const similarityRatio = 10
+
+// fuzzyContent - the form of a quote's content used to recognize near-duplicates
+// case, punctuation and whitespace differences are ignored
+func fuzzyContent(content string) []rune {
+	stripped := strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsNumber(r) {
+			return unicode.ToLower(r)
+		} else if unicode.IsSpace(r) {
+			return ' '
+		}
+		return -1
+	}, content)
+	return []rune(strings.Join(strings.Fields(stripped), " "))
+}
+
+// SimilarContent - reports whether two quotes are close enough to be the same quote
+func SimilarContent(first string, second string) bool {
+	a, b := fuzzyContent(first), fuzzyContent(second)
+	if len(a) == 0 || len(b) == 0 {
+		return false
+	}
+
+	allowed := max(len(a), len(b)) / similarityRatio
+	if abs(len(a)-len(b)) > allowed {
+		return false
+	}
+	return editDistance(a, b) <= allowed
+}
+
+// editDistance - the Levenshtein distance between two strings
+func editDistance(a []rune, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
@@ -0,0 +1,49 @@
+package data
+
+import "testing"
+
+func TestSimilarContent(t *testing.T) {
+	tests := []struct {
+		first  string
+		second string
+		want   bool
+	}{
+		{first: "I am the senate", second: "I am the senate", want: true},
+		{first: "I am the senate", second: "i AM the   Senate!", want: true},
+		{first: "It's over Anakin, I have the high ground", second: "Its over Anakin, I have the high grund", want: true},
+		{first: "It's over Anakin, I have the high ground", second: "It's over Anakin, I have the low ground", want: false},
+		{first: "hello", second: "hallo", want: false},
+		{first: "hello there", second: "hello there general kenobi", want: false},
+		{first: "!!!", second: "???", want: false},
+		{first: "", second: "", want: false},
+	}
+
+	for _, test := range tests {
+		if got := SimilarContent(test.first, test.second); got != test.want {
+			t.Errorf("SimilarContent(%q, %q) = %v, want %v", test.first, test.second, got, test.want)
+		}
+		if got := SimilarContent(test.second, test.first); got != test.want {
+			t.Errorf("SimilarContent(%q, %q) = %v, want %v", test.second, test.first, got, test.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a    string
+		b    string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "abc", b: "", want: 3},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "flaw", b: "lawn", want: 2},
+		{a: "héllo", b: "hello", want: 1},
+	}
+
+	for _, test := range tests {
+		if got := editDistance([]rune(test.a), []rune(test.b)); got != test.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
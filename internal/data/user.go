@@ -1,12 +1,41 @@
 package data
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
 // User - An object representing a User
 type User struct {
 	gorm.Model
-	Name      string // Name of the User
-	DiscordID string // Discord User ID
-	GuildID   uint   // database ID of the guild this user belongs to
-	Guild     Guild  // the guild this user belongs to
+	Name           string      // Name of the User
+	GlobalName     string      // Display name the User chose for their account
+	Nickname       string      // Nickname of the User in their guild
+	AvatarHash     string      // hash of the User's avatar, empty while they have a default one
+	DiscordID      string      `gorm:"uniqueIndex:idx_user_guild"` // Discord User ID
+	GuildID        uint        `gorm:"uniqueIndex:idx_user_guild"` // database ID of the guild this user belongs to
+	Guild          Guild       // the guild this user belongs to
+	OptOut         bool        // the user does not want to be quoted at all
+	RequireConsent bool        // quotes about the user are held until they consent
+	Aliases        []UserAlias // every name the user has been seen with
+	DepartedAt     *time.Time  // when the user left the guild, nil while they are a member
+}
+
+// DisplayName - the name shown for the user in the given display style
+// styles fall back to the next most general name when the user has not set one
+func (user User) DisplayName(display NameDisplay) string {
+	names := []string{user.Nickname, user.GlobalName, user.Name}
+	switch display {
+	case UsernameDisplay:
+		names = names[2:]
+	case GlobalNameDisplay:
+		names = names[1:]
+	}
+
+	for _, name := range names {
+		if len(name) > 0 {
+			return name
+		}
+	}
+	return user.Name
 }
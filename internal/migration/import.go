@@ -0,0 +1,131 @@
+package migration
+
+import (
+	"errors"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"strings"
+)
+
+// ImportReport - the outcome of importing a batch of quotes into a guild
+type ImportReport struct {
+	Imported   int              // quotes that were added
+	Duplicates int              // quotes skipped because the speaker already has them
+	Empty      int              // quotes skipped because they had no content
+	OptedOut   int              // quotes skipped because their speaker asked not to be quoted
+	Unknown    int              // quotes skipped because their status isn't one QuoteBot knows
+	Failed     int              // quotes that couldn't be stored
+	Unmatched  []UnmatchedQuote // quotes whose speaker is not a member of the guild
+	Waiting    []data.Quote     // imported quotes waiting on their speaker's consent or a moderator
+}
+
+// UnmatchedQuote - a quote that could not be attributed to a guild member
+type UnmatchedQuote struct {
+	Row   int              // 1-based position of the quote in the imported file
+	Quote data.QuoteExport // the quote as it was read
+}
+
+// UnmatchedSpeakers - the distinct speaker names of the unmatched quotes
+func (report ImportReport) UnmatchedSpeakers() []string {
+	seen := make(map[string]bool)
+	var speakers []string
+	for _, unmatched := range report.Unmatched {
+		name := unmatched.Quote.SpeakerName
+		if len(unmatched.Quote.SpeakerDiscordID) > 0 {
+			name = unmatched.Quote.SpeakerDiscordID
+		}
+		if !seen[name] {
+			seen[name] = true
+			speakers = append(speakers, name)
+		}
+	}
+	return speakers
+}
+
+// memberIndex - looks up the User entries of a guild by Discord ID or case-insensitive name
+type memberIndex struct {
+	byID    map[string]data.User
+	byName  map[string]data.User
+	byAlias map[string]data.User
+}
+
+// newMemberIndex indexes the users of a guild, along with their aliases ordered most recent first
+func newMemberIndex(users []data.User, aliases []data.UserAlias) memberIndex {
+	index := memberIndex{
+		byID:    make(map[string]data.User, len(users)),
+		byName:  make(map[string]data.User, len(users)),
+		byAlias: make(map[string]data.User, len(aliases)),
+	}
+	for _, user := range users {
+		index.byID[user.DiscordID] = user
+		index.byName[strings.ToLower(user.Name)] = user
+	}
+	for _, alias := range aliases {
+		key := strings.ToLower(alias.Alias)
+		if _, ok := index.byAlias[key]; !ok {
+			index.byAlias[key] = alias.User
+		}
+	}
+	return index
+}
+
+func (index memberIndex) find(discordID string, name string) (data.User, bool) {
+	if user, ok := index.byID[discordID]; ok && len(discordID) > 0 {
+		return user, true
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return data.User{}, false
+	}
+	if user, ok := index.byName[name]; ok {
+		return user, true
+	}
+	user, ok := index.byAlias[name]
+	return user, ok
+}
+
+// ImportQuotes - adds quotes read from an archive to a guild, matching speakers and submitters to its members
+// by Discord ID, current name or any alias they have gone by
+// quotes whose submitter can't be matched are attributed to the importer; quotes keep their exported status,
+// except that held quotes go to the moderators, speakers who opted out are skipped and speakers who require consent are asked for it
+func ImportQuotes(manager data.Manager, guild data.Guild, quotes []data.QuoteExport, importer data.User) ImportReport {
+	var report ImportReport
+	members := newMemberIndex(guild.Users, manager.FindGuildAliases(guild))
+
+	for index, quote := range quotes {
+		content := strings.TrimSpace(quote.Content)
+		if len(content) == 0 {
+			report.Empty++
+			continue
+		}
+
+		speaker, ok := members.find(quote.SpeakerDiscordID, quote.SpeakerName)
+		if !ok {
+			report.Unmatched = append(report.Unmatched, UnmatchedQuote{Row: index + 1, Quote: quote})
+			continue
+		}
+
+		submitter, ok := members.find(quote.SubmitterDiscordID, quote.SubmitterName)
+		if !ok {
+			submitter = importer
+		}
+
+		imported, err := manager.ImportQuote(content, speaker, submitter, guild, quote.Status, quote.CreatedAt)
+		switch {
+		case errors.Is(err, data.ErrOptedOut):
+			report.OptedOut++
+		case errors.Is(err, data.ErrUnknownStatus):
+			report.Unknown++
+		case err != nil:
+			report.Failed++
+		case imported.SpeakerID == 0:
+			report.Duplicates++
+		default:
+			report.Imported++
+			if imported.Status == data.QuoteConsent || imported.Status == data.QuotePending {
+				report.Waiting = append(report.Waiting, imported)
+			}
+		}
+	}
+
+	return report
+}
@@ -2,19 +2,27 @@ package migration
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/DeLucaJ/quotebot/internal/data"
 	"github.com/bwmarrin/discordgo"
+	"io/fs"
 	"log"
 	"os"
+	"sort"
 )
 
+// the legacy migration settings, only read to seed migration jobs in the database
 const migrateMapFile string = "./legacyData/migrate-data.json"
 const legacyQuotesFile string = "./legacyData/quotes.json"
 
+// MigrateData - the settings of a legacy migration as written in migrateMapFile
 type MigrateData struct {
 	Done        bool      `json:"done"`
 	BotUserName string    `json:"bot-user-name"`
+	GuildID     string    `json:"guild-id"`
 	GuildName   string    `json:"guild-name"`
+	QuotesFile  string    `json:"quotes-file"`
 	UserMap     []NameMap `json:"user-map"`
 }
 
@@ -28,91 +36,269 @@ type LegacyQuote struct {
 	Text    string `json:"Text"`
 }
 
-func itsNotTime(guildName string) bool {
-	return guildName != migrateData.GuildName
+// matches reports whether the settings are meant for the given guild
+func (migrateData MigrateData) matches(guildID string, guildName string) bool {
+	if len(migrateData.GuildID) > 0 {
+		return migrateData.GuildID == guildID
+	}
+	return migrateData.GuildName == guildName
 }
 
-var migrateData MigrateData
+// readMigrateData reads migrateMapFile, which holds either one MigrateData or a list of them
+func readMigrateData() ([]MigrateData, error) {
+	migrateDataRaw, err := os.ReadFile(migrateMapFile)
+	if err != nil {
+		return nil, err
+	}
 
-func legacyToModern(manager data.Manager, migrateMap map[string]string, legacyQuote LegacyQuote, guild data.Guild) {
-	if !manager.UserExistsByName(migrateMap[legacyQuote.Speaker], guild) {
-		return
+	var migrateData []MigrateData
+	if err = json.Unmarshal(migrateDataRaw, &migrateData); err == nil {
+		return migrateData, nil
 	}
-	if len(legacyQuote.Text) == 0 {
-		return
+
+	var single MigrateData
+	if err = json.Unmarshal(migrateDataRaw, &single); err != nil {
+		return nil, err
 	}
+	return []MigrateData{single}, nil
+}
 
-	speaker := manager.FindUserByName(migrateMap[legacyQuote.Speaker], guild.ID)
-	submitter := manager.FindUserByName(migrateData.BotUserName, guild.ID)
+// newMigrationJob converts legacy migration settings into a job for the given guild
+func newMigrationJob(migrateData MigrateData, guildID string) data.MigrationJob {
+	job := data.MigrationJob{
+		GuildDiscordID: guildID,
+		QuotesFile:     migrateData.QuotesFile,
+		SubmitterName:  migrateData.BotUserName,
+		Status:         data.MigrationPending,
+	}
+	if len(job.QuotesFile) == 0 {
+		job.QuotesFile = legacyQuotesFile
+	}
+	if migrateData.Done {
+		job.Status = data.MigrationDone
+	}
 
-	manager.AddLegacyQuote(legacyQuote.Text, speaker, submitter, guild)
+	for _, entry := range migrateData.UserMap {
+		for _, oldName := range entry.OldNames {
+			job.NameMaps = append(job.NameMaps, data.MigrationNameMap{OldName: oldName, UserName: entry.UserName})
+		}
+	}
+	return job
 }
 
-func init() {
-	migrateDataRaw, err := os.ReadFile(migrateMapFile)
-	if err != nil {
-		log.Panicf("Failled to read migrate data file")
+// legacyMigrationJob builds the job of a guild from migrateMapFile without storing it
+// the returned job has no QuotesFile if the file has no settings for the guild
+func legacyMigrationJob(guildID string, guildName string) data.MigrationJob {
+	migrateData, err := readMigrateData()
+	if errors.Is(err, fs.ErrNotExist) {
+		return data.MigrationJob{}
+	} else if err != nil {
+		log.Printf("Failed to read migrate data: %v", err)
+		return data.MigrationJob{}
 	}
 
-	err = json.Unmarshal(migrateDataRaw, &migrateData)
+	for _, entry := range migrateData {
+		if entry.matches(guildID, guildName) {
+			return newMigrationJob(entry, guildID)
+		}
+	}
+	return data.MigrationJob{}
+}
+
+// seedMigrationJob creates the job of a guild from migrateMapFile, if the file has settings for it
+func seedMigrationJob(manager data.Manager, guild *discordgo.Guild) data.MigrationJob {
+	job := legacyMigrationJob(guild.ID, guild.Name)
+	if len(job.QuotesFile) == 0 {
+		return job
+	}
+
+	manager.SaveMigrationJob(&job)
+	log.Printf("Created %s migration job for %s", job.Status, guild.Name)
+	return job
+}
+
+// readLegacyQuotes reads a quotes file written by the legacy QuoteBot
+func readLegacyQuotes(file string) ([]LegacyQuote, error) {
+	legacyQuotesRaw, err := os.ReadFile(file)
 	if err != nil {
-		log.Panicf("Failed to unmarshal migrate map")
+		return nil, err
 	}
+
+	var legacyQuotes []LegacyQuote
+	err = json.Unmarshal(legacyQuotesRaw, &legacyQuotes)
+	return legacyQuotes, err
 }
 
-func AttemptMigrateLegacyQuotes(manager data.Manager, session *discordgo.Session, event *discordgo.GuildCreate) {
-	if itsNotTime(event.Guild.Name) {
-		return
+// legacyToModern converts legacy quotes into importable quotes, renaming speakers through the job's name maps
+func legacyToModern(job data.MigrationJob, legacyQuotes []LegacyQuote) []data.QuoteExport {
+	migrateMap := nameMapsByOldName(job)
+
+	quotes := make([]data.QuoteExport, len(legacyQuotes))
+	for index, legacyQuote := range legacyQuotes {
+		nameMap := migrateMap[legacyQuote.Speaker]
+		quotes[index] = data.QuoteExport{
+			Content:          legacyQuote.Text,
+			SpeakerName:      nameMap.UserName,
+			SpeakerDiscordID: nameMap.UserDiscordID,
+			SubmitterName:    job.SubmitterName,
+		}
 	}
+	return quotes
+}
 
-	if migrateData.Done {
-		return
+func nameMapsByOldName(job data.MigrationJob) map[string]data.MigrationNameMap {
+	migrateMap := make(map[string]data.MigrationNameMap, len(job.NameMaps))
+	for _, nameMap := range job.NameMaps {
+		migrateMap[nameMap.OldName] = nameMap
 	}
+	return migrateMap
+}
 
-	legacyQuotesRaw, err := os.ReadFile(legacyQuotesFile)
+// runMigrationJob imports the legacy quotes of a job and records the outcome on it
+// it returns the imported quotes waiting on their speaker's consent or a moderator
+func runMigrationJob(manager data.Manager, session *discordgo.Session, job *data.MigrationJob) ([]data.Quote, error) {
+	legacyQuotes, err := readLegacyQuotes(job.QuotesFile)
 	if err != nil {
-		log.Panicf("Failed to read legacy quotes file")
+		return nil, fmt.Errorf("reading %s: %w", job.QuotesFile, err)
 	}
 
-	var migrateMap map[string]string = make(map[string]string)
-	for _, entry := range migrateData.UserMap {
-		for _, oldName := range entry.OldNames {
-			migrateMap[oldName] = entry.UserName
-		}
+	guild := manager.FindGuildMembers(job.GuildDiscordID)
+	if guild.ID == 0 {
+		return nil, fmt.Errorf("guild %s is not known yet", job.GuildDiscordID)
 	}
+	importer := manager.FindUser(session.State.User.ID, guild.ID)
 
-	// get old quotes
-	var legacyQuotes []LegacyQuote
-	err = json.Unmarshal(legacyQuotesRaw, &legacyQuotes)
+	report := ImportQuotes(manager, guild, legacyToModern(*job, legacyQuotes), importer)
+
+	job.Imported = report.Imported
+	job.Duplicates = report.Duplicates
+	job.Empty = report.Empty
+	job.Unmatched = len(report.Unmatched)
+	job.OptedOut = report.OptedOut
+	job.Failed = report.Failed
+	return report.Waiting, nil
+}
+
+// completeMigrationJob runs a job and stores its outcome, reporting whether it succeeded
+// along with the imported quotes waiting on their speaker's consent or a moderator
+func completeMigrationJob(manager data.Manager, session *discordgo.Session, job *data.MigrationJob) ([]data.Quote, bool) {
+	waiting, err := runMigrationJob(manager, session, job)
 	if err != nil {
-		log.Panicf("Failed to unmarshal legacy quotes")
+		log.Printf("Legacy migration of %s failed: %v", job.GuildDiscordID, err)
+		job.Status = data.MigrationFailed
+		job.Error = err.Error()
+		manager.SaveMigrationJob(job)
+		return nil, false
 	}
 
-	dataGuild := manager.FindGuild(event.Guild.ID)
+	job.Status = data.MigrationDone
+	job.Error = ""
+	manager.SaveMigrationJob(job)
+	log.Printf("Migrated legacy quotes of %s: %d imported, %d duplicates, %d empty, %d unmatched, %d opted out, %d failed",
+		job.GuildDiscordID, job.Imported, job.Duplicates, job.Empty, job.Unmatched, job.OptedOut, job.Failed)
+	return waiting, true
+}
 
-	// loop through all old quotes and process them
-	for _, legacyQuote := range legacyQuotes {
-		if _, ok := migrateMap[legacyQuote.Speaker]; ok {
-			legacyToModern(manager, migrateMap, legacyQuote, dataGuild)
-		}
+// AttemptMigrateLegacyQuotes - runs the pending migration job of a guild, if it has one
+// it returns the imported quotes waiting on their speaker's consent or a moderator
+func AttemptMigrateLegacyQuotes(manager data.Manager, session *discordgo.Session, event *discordgo.GuildCreate) []data.Quote {
+	job := manager.FindMigrationJob(event.Guild.ID)
+	if job.ID == 0 {
+		job = seedMigrationJob(manager, event.Guild)
 	}
 
-	for _, channel := range event.Guild.Channels {
-		if channel.ID == event.Guild.ID {
-			_, _ = session.ChannelMessageSend(channel.ID, "Legacy quotes have been migrated to QuoteBotX!")
-			break
-		}
+	if job.ID == 0 || job.Status != data.MigrationPending {
+		return nil
 	}
 
-	migrateData.Done = true
+	waiting, ok := completeMigrationJob(manager, session, &job)
+	if !ok {
+		return nil
+	}
 
-	migrateDataJson, err := json.MarshalIndent(migrateData, "", "\t")
-	if err != nil {
-		log.Printf("Error marhsalling migrate data JSON")
+	settings := manager.FindGuildSettings(manager.FindGuildEntry(event.Guild.ID))
+	if channelID := settings.AnnouncementChannel(event.Guild); len(channelID) > 0 {
+		_, _ = session.ChannelMessageSend(channelID, "Legacy quotes have been migrated to QuoteBotX!")
+	}
+	return waiting
+}
+
+// RetryMigration - runs the migration job of a guild again after it failed
+// it also returns the imported quotes waiting on their speaker's consent or a moderator
+func RetryMigration(manager data.Manager, session *discordgo.Session, guildID string) (data.MigrationJob, []data.Quote) {
+	job := manager.FindMigrationJob(guildID)
+	if job.ID == 0 || job.Status != data.MigrationFailed {
+		return job, nil
+	}
+
+	waiting, _ := completeMigrationJob(manager, session, &job)
+	return job, waiting
+}
+
+// RunMigration - runs the migration job of a guild whatever its status
+// quotes imported by an earlier run are skipped as duplicates
+// it also returns the imported quotes waiting on their speaker's consent or a moderator
+func RunMigration(manager data.Manager, session *discordgo.Session, guildID string) (data.MigrationJob, []data.Quote) {
+	job := manager.FindMigrationJob(guildID)
+	if job.ID == 0 {
+		return job, nil
 	}
 
-	err = os.WriteFile(migrateMapFile, migrateDataJson, 0644)
+	waiting, _ := completeMigrationJob(manager, session, &job)
+	return job, waiting
+}
+
+// EnsureMigrationJob - finds the migration job of a guild, creating one from migrateMapFile
+// or for the default legacy quotes file if it has none yet
+func EnsureMigrationJob(manager data.Manager, guild *discordgo.Guild) data.MigrationJob {
+	job := manager.FindMigrationJob(guild.ID)
+	if job.ID != 0 {
+		return job
+	}
+
+	job = seedMigrationJob(manager, guild)
+	if job.ID != 0 {
+		return job
+	}
+
+	if _, err := os.Stat(legacyQuotesFile); err != nil {
+		return job
+	}
+	job = data.MigrationJob{
+		GuildDiscordID: guild.ID,
+		QuotesFile:     legacyQuotesFile,
+		Status:         data.MigrationPending,
+	}
+	manager.SaveMigrationJob(&job)
+	return job
+}
+
+// LegacySpeaker - a distinct speaker name of a legacy quotes file
+type LegacySpeaker struct {
+	Name    string                // the speaker name used by the legacy bot
+	Quotes  int                   // how many legacy quotes the speaker has
+	NameMap data.MigrationNameMap // the current mapping of the speaker, with no UserName if unmapped
+}
+
+// LegacySpeakers - the distinct speakers of a job's quotes file, sorted by name
+func LegacySpeakers(job data.MigrationJob) ([]LegacySpeaker, error) {
+	legacyQuotes, err := readLegacyQuotes(job.QuotesFile)
 	if err != nil {
-		log.Printf("Error writing migrate data JSON to file")
+		return nil, fmt.Errorf("reading %s: %w", job.QuotesFile, err)
+	}
+
+	counts := make(map[string]int)
+	for _, legacyQuote := range legacyQuotes {
+		counts[legacyQuote.Speaker]++
+	}
+
+	migrateMap := nameMapsByOldName(job)
+	speakers := make([]LegacySpeaker, 0, len(counts))
+	for name, count := range counts {
+		speakers = append(speakers, LegacySpeaker{Name: name, Quotes: count, NameMap: migrateMap[name]})
 	}
+	sort.Slice(speakers, func(i, j int) bool {
+		return speakers[i].Name < speakers[j].Name
+	})
+	return speakers, nil
 }
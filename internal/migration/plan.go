@@ -0,0 +1,181 @@
+package migration
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Plan - what running a migration job would do, worked out without writing anything
+type Plan struct {
+	GuildDiscordID string                  // Discord ID of the guild being migrated
+	GuildName      string                  // name of the guild, if it is known
+	QuotesFile     string                  // the legacy quotes file that was read
+	Total          int                     // number of legacy quotes in the file
+	Mapped         map[string]*SpeakerPlan // quotes that would be imported, by the User they map to
+	Unmapped       map[string]int          // legacy speakers without a name map, with their quote counts
+	Unknown        map[string]int          // mapped names that match no guild member, with their quote counts
+	Duplicates     []LegacyQuote           // quotes that already exist or appear twice in the file
+	Empty          []LegacyQuote           // quotes without any text
+}
+
+// SpeakerPlan - the legacy quotes that would be attributed to a single guild member
+type SpeakerPlan struct {
+	UserName string         // the name of the User the quotes map to
+	OldNames map[string]int // the legacy speaker names mapped to the User, with their quote counts
+	Quotes   int            // the number of quotes that would be imported
+}
+
+// PlanMigration - works out what the migration job of a guild would do
+// the job is taken from the database, or from migrateMapFile if the guild has none yet
+func PlanMigration(manager data.Manager, guildID string) (Plan, error) {
+	guild := manager.FindGuildMembers(guildID)
+
+	job := manager.FindMigrationJob(guildID)
+	if job.ID == 0 {
+		job = legacyMigrationJob(guildID, guild.Name)
+	}
+	if len(job.QuotesFile) == 0 {
+		return Plan{}, fmt.Errorf("no migration is configured for guild %s", guildID)
+	}
+
+	legacyQuotes, err := readLegacyQuotes(job.QuotesFile)
+	if err != nil {
+		return Plan{}, fmt.Errorf("reading %s: %w", job.QuotesFile, err)
+	}
+
+	return planMigrationJob(manager, guild, job, legacyQuotes), nil
+}
+
+func planMigrationJob(manager data.Manager, guild data.Guild, job data.MigrationJob, legacyQuotes []LegacyQuote) Plan {
+	plan := Plan{
+		GuildDiscordID: job.GuildDiscordID,
+		GuildName:      guild.Name,
+		QuotesFile:     job.QuotesFile,
+		Total:          len(legacyQuotes),
+		Mapped:         make(map[string]*SpeakerPlan),
+		Unmapped:       make(map[string]int),
+		Unknown:        make(map[string]int),
+	}
+
+	migrateMap := nameMapsByOldName(job)
+	members := newMemberIndex(guild.Users, manager.FindGuildAliases(guild))
+	seen := make(map[string]bool)
+
+	for _, legacyQuote := range legacyQuotes {
+		content := strings.TrimSpace(legacyQuote.Text)
+		if len(content) == 0 {
+			plan.Empty = append(plan.Empty, legacyQuote)
+			continue
+		}
+
+		nameMap, ok := migrateMap[legacyQuote.Speaker]
+		if !ok {
+			plan.Unmapped[legacyQuote.Speaker]++
+			continue
+		}
+
+		speaker, ok := members.find(nameMap.UserDiscordID, nameMap.UserName)
+		if !ok {
+			plan.Unknown[nameMap.UserName]++
+			continue
+		}
+
+		key := speaker.DiscordID + "\x00" + data.ContentHash(content)
+		if seen[key] || manager.QuoteExists(data.Quote{Content: content, SpeakerID: speaker.ID, GuildID: guild.ID}) {
+			plan.Duplicates = append(plan.Duplicates, legacyQuote)
+			continue
+		}
+		seen[key] = true
+
+		speakerPlan, ok := plan.Mapped[speaker.Name]
+		if !ok {
+			speakerPlan = &SpeakerPlan{UserName: speaker.Name, OldNames: make(map[string]int)}
+			plan.Mapped[speaker.Name] = speakerPlan
+		}
+		speakerPlan.OldNames[legacyQuote.Speaker]++
+		speakerPlan.Quotes++
+	}
+
+	return plan
+}
+
+// Imported - the number of quotes the migration would add
+func (plan Plan) Imported() int {
+	imported := 0
+	for _, speakerPlan := range plan.Mapped {
+		imported += speakerPlan.Quotes
+	}
+	return imported
+}
+
+// sortedCounts lists the keys of a count map, largest count first
+func sortedCounts(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// WriteReport - writes a human-readable report of the plan
+func (plan Plan) WriteReport(writer io.Writer) error {
+	var report strings.Builder
+
+	_, _ = fmt.Fprintf(&report, "Legacy migration dry run for %s (%s)\n", plan.GuildName, plan.GuildDiscordID)
+	_, _ = fmt.Fprintf(&report, "Quotes file: %s\n\n", plan.QuotesFile)
+	_, _ = fmt.Fprintf(&report, "%d legacy quotes: %d would be imported, %d duplicates, %d empty, %d unmapped, %d unknown\n",
+		plan.Total, plan.Imported(), len(plan.Duplicates), len(plan.Empty), sum(plan.Unmapped), sum(plan.Unknown))
+
+	userNames := make(map[string]int, len(plan.Mapped))
+	for userName, speakerPlan := range plan.Mapped {
+		userNames[userName] = speakerPlan.Quotes
+	}
+	report.WriteString("\nMapped speakers:\n")
+	for _, userName := range sortedCounts(userNames) {
+		speakerPlan := plan.Mapped[userName]
+		_, _ = fmt.Fprintf(&report, "  %s: %d quotes\n", userName, speakerPlan.Quotes)
+		for _, oldName := range sortedCounts(speakerPlan.OldNames) {
+			_, _ = fmt.Fprintf(&report, "    from %q: %d\n", oldName, speakerPlan.OldNames[oldName])
+		}
+	}
+
+	report.WriteString("\nUnmapped legacy speakers (no entry in the user map):\n")
+	for _, speaker := range sortedCounts(plan.Unmapped) {
+		_, _ = fmt.Fprintf(&report, "  %q: %d quotes\n", speaker, plan.Unmapped[speaker])
+	}
+
+	report.WriteString("\nMapped names without a matching guild member:\n")
+	for _, userName := range sortedCounts(plan.Unknown) {
+		_, _ = fmt.Fprintf(&report, "  %q: %d quotes\n", userName, plan.Unknown[userName])
+	}
+
+	report.WriteString("\nDuplicates:\n")
+	for _, legacyQuote := range plan.Duplicates {
+		_, _ = fmt.Fprintf(&report, "  %s: %q\n", legacyQuote.Speaker, legacyQuote.Text)
+	}
+
+	report.WriteString("\nEmpty quotes:\n")
+	for _, legacyQuote := range plan.Empty {
+		_, _ = fmt.Fprintf(&report, "  %s\n", legacyQuote.Speaker)
+	}
+
+	_, err := io.WriteString(writer, report.String())
+	return err
+}
+
+func sum(counts map[string]int) int {
+	total := 0
+	for _, count := range counts {
+		total += count
+	}
+	return total
+}
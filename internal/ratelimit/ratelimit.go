@@ -0,0 +1,158 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// how many takes pass between sweeps of the buckets that have filled up again
+const pruneEvery = 1000
+
+// Rule - a bucket of Burst tokens that refills completely over Per, the zero Rule limits nothing
+type Rule struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Off - the Rule that limits nothing
+var Off = Rule{}
+
+// ParseRule - reads a Rule written as "<tokens>/<duration>", such as "5/30s", or "off"
+func ParseRule(text string) (Rule, error) {
+	text = strings.TrimSpace(text)
+	if text == "off" {
+		return Off, nil
+	}
+
+	burstText, perText, ok := strings.Cut(text, "/")
+	if !ok {
+		return Off, fmt.Errorf("expected <tokens>/<duration> such as \"5/30s\", or \"off\", got %q", text)
+	}
+
+	burst, err := strconv.Atoi(strings.TrimSpace(burstText))
+	if err != nil || burst <= 0 {
+		return Off, fmt.Errorf("expected a positive number of tokens, got %q", burstText)
+	}
+
+	per, err := time.ParseDuration(strings.TrimSpace(perText))
+	if err != nil || per <= 0 {
+		return Off, fmt.Errorf("expected a positive duration such as \"30s\", got %q", perText)
+	}
+	return Rule{Burst: burst, Per: per}, nil
+}
+
+// Enabled - whether the Rule limits anything
+func (rule Rule) Enabled() bool {
+	return rule.Burst > 0 && rule.Per > 0
+}
+
+func (rule Rule) String() string {
+	if !rule.Enabled() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", rule.Burst, rule.Per)
+}
+
+// Request - tokens wanted from the bucket of a key
+type Request struct {
+	Key  string
+	Rule Rule
+	Cost int
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	rule    Rule
+}
+
+// refill adds the tokens earned since the bucket was last updated
+func (bucket *bucket) refill(rule Rule, now time.Time) {
+	// a bucket whose rule changed starts over with the new one
+	if bucket.rule != rule {
+		bucket.tokens = float64(rule.Burst)
+		bucket.rule = rule
+	}
+
+	earned := now.Sub(bucket.updated).Seconds() * float64(rule.Burst) / rule.Per.Seconds()
+	bucket.tokens = min(float64(rule.Burst), bucket.tokens+earned)
+	bucket.updated = now
+}
+
+// Limiter - token buckets by key, safe for concurrent use
+type Limiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+// New - makes an empty Limiter
+func New() *Limiter {
+	return &Limiter{buckets: make(map[string]*bucket)}
+}
+
+// Take - takes the tokens of every request if all of their buckets hold enough, otherwise takes none
+// and returns how long to wait before they would
+func (limiter *Limiter) Take(requests ...Request) (time.Duration, bool) {
+	return limiter.takeAt(time.Now(), requests...)
+}
+
+// takeAt is Take at a given time
+func (limiter *Limiter) takeAt(now time.Time, requests ...Request) (time.Duration, bool) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.prune(now)
+
+	var wait time.Duration
+	for _, request := range requests {
+		if !request.Rule.Enabled() {
+			continue
+		}
+
+		entry := limiter.bucket(request.Key, request.Rule, now)
+		cost := float64(min(request.Cost, request.Rule.Burst))
+		if entry.tokens < cost {
+			missing := (cost - entry.tokens) * request.Rule.Per.Seconds() / float64(request.Rule.Burst)
+			wait = max(wait, time.Duration(missing*float64(time.Second)))
+		}
+	}
+	if wait > 0 {
+		return wait, false
+	}
+
+	for _, request := range requests {
+		if request.Rule.Enabled() {
+			limiter.buckets[request.Key].tokens -= float64(min(request.Cost, request.Rule.Burst))
+		}
+	}
+	return 0, true
+}
+
+func (limiter *Limiter) bucket(key string, rule Rule, now time.Time) *bucket {
+	entry, ok := limiter.buckets[key]
+	if !ok {
+		entry = &bucket{tokens: float64(rule.Burst), updated: now, rule: rule}
+		limiter.buckets[key] = entry
+	}
+	entry.refill(rule, now)
+	return entry
+}
+
+// prune forgets the buckets that have filled up again, they would start out full anyway
+func (limiter *Limiter) prune(now time.Time) {
+	limiter.takes++
+	if limiter.takes < pruneEvery {
+		return
+	}
+	limiter.takes = 0
+
+	for key, entry := range limiter.buckets {
+		if now.Sub(entry.updated) >= entry.rule.Per {
+			delete(limiter.buckets, key)
+		}
+	}
+}
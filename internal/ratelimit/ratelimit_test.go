@@ -0,0 +1,173 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    Rule
+		wantErr bool
+	}{
+		{text: "5/30s", want: Rule{Burst: 5, Per: 30 * time.Second}},
+		{text: " 1 / 1m ", want: Rule{Burst: 1, Per: time.Minute}},
+		{text: "off", want: Off},
+		{text: "", wantErr: true},
+		{text: "5", wantErr: true},
+		{text: "0/30s", wantErr: true},
+		{text: "-1/30s", wantErr: true},
+		{text: "five/30s", wantErr: true},
+		{text: "5/0s", wantErr: true},
+		{text: "5/soon", wantErr: true},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRule(test.text)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ParseRule(%q) = %v, want an error", test.text, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseRule(%q) returned %v", test.text, err)
+		} else if got != test.want {
+			t.Errorf("ParseRule(%q) = %v, want %v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestRuleString(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		want string
+	}{
+		{rule: Rule{Burst: 5, Per: 30 * time.Second}, want: "5/30s"},
+		{rule: Off, want: "off"},
+		{rule: Rule{Burst: 5}, want: "off"},
+	}
+
+	for _, test := range tests {
+		if got := test.rule.String(); got != test.want {
+			t.Errorf("%#v.String() = %q, want %q", test.rule, got, test.want)
+		}
+
+		// every enabled rule reads back as itself
+		if test.rule.Enabled() {
+			if parsed, err := ParseRule(test.want); err != nil || parsed != test.rule {
+				t.Errorf("ParseRule(%q) = %v, %v, want %v", test.want, parsed, err, test.rule)
+			}
+		}
+	}
+}
+
+func TestTake(t *testing.T) {
+	rule := Rule{Burst: 3, Per: 30 * time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type take struct {
+		after    time.Duration // since start
+		requests []Request
+		wantOK   bool
+		wantWait time.Duration
+	}
+
+	tests := []struct {
+		name  string
+		takes []take
+	}{
+		{
+			name: "burst then refuse",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantWait: 10 * time.Second},
+			},
+		},
+		{
+			name: "refills over time",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 3}}, wantOK: true},
+				{after: 5 * time.Second, requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantWait: 5 * time.Second},
+				{after: 10 * time.Second, requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantOK: true},
+				{after: 10 * time.Second, requests: []Request{{Key: "a", Rule: rule, Cost: 1}}, wantWait: 10 * time.Second},
+			},
+		},
+		{
+			name: "costs above the burst are clamped",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 10}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 10}}, wantWait: 30 * time.Second},
+			},
+		},
+		{
+			name: "keys have their own buckets",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 3}}, wantOK: true},
+				{requests: []Request{{Key: "b", Rule: rule, Cost: 3}}, wantOK: true},
+			},
+		},
+		{
+			name: "refused requests take nothing",
+			takes: []take{
+				{requests: []Request{{Key: "b", Rule: rule, Cost: 3}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 2}, {Key: "b", Rule: rule, Cost: 1}}, wantWait: 10 * time.Second},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 3}}, wantOK: true},
+			},
+		},
+		{
+			name: "the longest wait is returned",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 3}, {Key: "b", Rule: rule, Cost: 2}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 2}, {Key: "b", Rule: rule, Cost: 2}}, wantWait: 20 * time.Second},
+			},
+		},
+		{
+			name: "disabled rules limit nothing",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: Off, Cost: 100}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: Off, Cost: 100}}, wantOK: true},
+			},
+		},
+		{
+			name: "a changed rule starts over",
+			takes: []take{
+				{requests: []Request{{Key: "a", Rule: rule, Cost: 3}}, wantOK: true},
+				{requests: []Request{{Key: "a", Rule: Rule{Burst: 5, Per: time.Minute}, Cost: 5}}, wantOK: true},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			limiter := New()
+			for i, take := range test.takes {
+				wait, ok := limiter.takeAt(start.Add(take.after), take.requests...)
+				if ok != take.wantOK || wait != take.wantWait {
+					t.Errorf("take %d = %v, %v, want %v, %v", i, wait, ok, take.wantWait, take.wantOK)
+				}
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	rule := Rule{Burst: 1, Per: time.Second}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	limiter := New()
+	limiter.takeAt(start, Request{Key: "old", Rule: rule, Cost: 1})
+	for i := 1; i < pruneEvery; i++ {
+		limiter.takeAt(start.Add(time.Minute), Request{Key: "new", Rule: Rule{Burst: pruneEvery, Per: time.Hour}, Cost: 1})
+	}
+
+	if _, ok := limiter.buckets["old"]; ok {
+		t.Error("a bucket that filled up again was kept")
+	}
+	if _, ok := limiter.buckets["new"]; !ok {
+		t.Error("a bucket in use was pruned")
+	}
+}
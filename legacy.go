@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strings"
+)
+
+// the prefix of the commands understood by the original QuoteBot
+const legacyPrefix = "!quote"
+
+// messageCreateHandler answers the legacy !quote prefix commands in guilds that opted in
+func messageCreateHandler(manager data.Manager, limits rateLimits) func(*discordgo.Session, *discordgo.MessageCreate) {
+	return func(session *discordgo.Session, message *discordgo.MessageCreate) {
+		if message.Author == nil || message.Author.Bot || len(message.GuildID) == 0 {
+			return
+		}
+
+		args := strings.Fields(message.Content)
+		if len(args) == 0 || args[0] != legacyPrefix {
+			return
+		}
+
+		guild := manager.FindGuildEntry(message.GuildID)
+		if guild.ID == 0 {
+			return
+		}
+
+		settings := manager.FindGuildSettings(guild)
+		if !settings.LegacyCommands {
+			return
+		}
+
+		// legacy commands can't be answered privately or moved, so they are ignored outside the allowed channels
+		if !manager.FindChannelRules(guild).Allows(message.ChannelID) {
+			return
+		}
+
+		var response discordgo.InteractionResponse
+		wait, ok := limits.take(settings, message.GuildID, message.ChannelID, message.Author.ID, 1)
+		if ok {
+			response = legacyCommandResponse(manager, session, message, guild, args[1:])
+		} else {
+			response = slowDownResponse(wait)
+		}
+
+		_, err := session.ChannelMessageSendComplex(message.ChannelID, responseToMessage(response, message.Reference()))
+		if err != nil {
+			log.Printf("Unable to answer legacy command: %v", err)
+		}
+	}
+}
+
+// legacyCommandResponse runs a legacy command and answers it the way the equivalent slash command would
+func legacyCommandResponse(manager data.Manager, session *discordgo.Session, message *discordgo.MessageCreate, guild data.Guild, args []string) discordgo.InteractionResponse {
+	if len(args) == 0 {
+		return getQuotesResponse(session, manager.GetNRandomQuotes(message.GuildID, minAmount), false)
+	}
+
+	switch args[0] {
+	case "add":
+		if len(args) < 3 {
+			return emptyResponse("Missing Arguments for Add.\nUse command \"!quote add <Name> <Quote>\".")
+		}
+
+		if !memberHasCapability(manager, guild, legacyMember(session, message), data.AddCapability) {
+			return emptyResponse("Sorry, you aren't allowed to add quotes in this server")
+		}
+
+		speaker := resolveLegacyName(manager, guild, args[1])
+		if speaker.ID == 0 {
+			return emptyResponse(fmt.Sprintf("Sorry, I don't know anyone called %s", args[1]))
+		}
+
+		content := strings.Trim(strings.Join(args[2:], " "), `'" `)
+		speakerUser := &discordgo.User{ID: speaker.DiscordID, Username: speaker.Name}
+
+		quote := manager.AddQuote(content, speakerUser, message.Author, message.GuildID)
+		dispatchNewQuote(manager, session, quote, message.GuildID)
+		return newQuoteResponse(manager, session, quote)
+	case "by":
+		if len(args) != 2 {
+			return emptyResponse("Invalid args for Quote By.\nUse command \"!quote by <Name>\".")
+		}
+
+		speaker := resolveLegacyName(manager, guild, args[1])
+		if speaker.ID == 0 {
+			return emptyResponse(fmt.Sprintf("Sorry, I don't know anyone called %s", args[1]))
+		}
+
+		return getQuotesResponse(session, manager.GetNRandomQuotesBySpeaker(speaker.DiscordID, message.GuildID, minAmount), false)
+	default:
+		return getQuotesResponse(session, manager.GetNRandomQuotes(message.GuildID, minAmount), false)
+	}
+}
+
+// resolveLegacyName finds the user a legacy command refers to, either by mention or by name
+func resolveLegacyName(manager data.Manager, guild data.Guild, name string) data.User {
+	if strings.HasPrefix(name, "<@") && strings.HasSuffix(name, ">") {
+		discordID := strings.TrimPrefix(strings.TrimSuffix(name[2:], ">"), "!")
+		if manager.UserExists(discordID, guild) {
+			return manager.FindUser(discordID, guild.ID)
+		}
+		return data.User{}
+	}
+
+	return manager.ResolveUser(name, guild.ID)
+}
+
+// responseToMessage sends the data of an interaction response as a regular message
+func responseToMessage(response discordgo.InteractionResponse, reference *discordgo.MessageReference) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content:    response.Data.Content,
+		Embeds:     response.Data.Embeds,
+		Components: response.Data.Components,
+		Files:      response.Data.Files,
+		Reference:  reference,
+	}
+}
+
+// legacyMember is the author of a legacy command as a member, with the permissions they have in its channel
+// messages carry the member's roles but not their permissions
+func legacyMember(session *discordgo.Session, message *discordgo.MessageCreate) *discordgo.Member {
+	if message.Member == nil {
+		return nil
+	}
+
+	member := *message.Member
+	permissions, err := session.UserChannelPermissions(message.Author.ID, message.ChannelID)
+	if err != nil {
+		log.Printf("Failed to work out the permissions of %s: %v", message.Author.Username, err)
+	}
+	member.Permissions = permissions
+	return &member
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"time"
+)
+
+// how long the data of a guild that removed the bot is kept if the configuration doesn't say
+const defaultGuildRetention = 30 * 24 * time.Hour
+
+// how often guilds past their retention period are purged
+const purgeInterval = time.Hour
+
+// how long a quote held as a near-duplicate waits for its submitter before it is dropped
+const heldQuoteLifetime = 24 * time.Hour
+
+func memberRemoveHandler(manager data.Manager) func(*discordgo.Session, *discordgo.GuildMemberRemove) {
+	return func(session *discordgo.Session, remove *discordgo.GuildMemberRemove) {
+		guild := manager.FindGuildEntry(remove.GuildID)
+
+		manager.MarkUserDeparted(remove.User.ID, guild)
+	}
+}
+
+// guildDeleteHandler marks guilds that removed the bot, purging them right away if nothing is retained
+func guildDeleteHandler(manager data.Manager, retention time.Duration) func(*discordgo.Session, *discordgo.GuildDelete) {
+	return func(session *discordgo.Session, event *discordgo.GuildDelete) {
+		// an unavailable guild is an outage, the bot is still a member
+		if event.Guild.Unavailable {
+			return
+		}
+
+		if retention > 0 {
+			manager.MarkGuildLeft(event.Guild.ID)
+			log.Printf("Left guild %s, its data is kept for %s", event.Guild.ID, retention)
+			return
+		}
+
+		guild := manager.FindGuildEntry(event.Guild.ID)
+		if guild.ID == 0 {
+			return
+		}
+		if err := manager.PurgeGuild(guild); err != nil {
+			log.Printf("Error purging guild %s: %v", guild.Name, err)
+		}
+	}
+}
+
+// schedulePurges purges guilds past their retention period until stop is closed
+func schedulePurges(manager data.Manager, retention time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if purged := manager.PurgeLeftGuilds(time.Now().Add(-retention)); purged > 0 {
+			log.Printf("Purged %d guilds past their retention period", purged)
+		}
+		if purged := manager.PurgeHeldQuotes(time.Now().Add(-heldQuoteLifetime)); purged > 0 {
+			log.Printf("Purged %d held quotes nobody confirmed", purged)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
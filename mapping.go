@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/DeLucaJ/quotebot/internal/migration"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// each page of the speaker mapping wizard leaves one action row for its buttons
+const speakersPerPage = 4
+
+func adminMapSpeakersHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction) {
+	guild, err := session.State.Guild(interaction.GuildID)
+	if err != nil {
+		guild = &discordgo.Guild{ID: interaction.GuildID, Name: manager.FindGuild(interaction.GuildID).Name}
+	}
+
+	var response discordgo.InteractionResponse
+	job := migration.EnsureMigrationJob(manager, guild)
+	if job.ID == 0 {
+		response = ephemeralResponse("This server has no legacy quotes to migrate")
+	} else {
+		response = discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseChannelMessageWithSource,
+			Data: speakerMapPage(job, 0),
+		}
+		response.Data.Flags = discordgo.MessageFlagsEphemeral
+	}
+
+	respond(session, interaction, response)
+}
+
+// speakerMapPage lists a page of legacy speakers, each with a user select to map it to a member
+func speakerMapPage(job data.MigrationJob, page int) *discordgo.InteractionResponseData {
+	speakers, err := migration.LegacySpeakers(job)
+	if err != nil {
+		log.Printf("Failed to list legacy speakers of %s: %v", job.GuildDiscordID, err)
+		return &discordgo.InteractionResponseData{
+			Content:    "Sorry, I couldn't read the legacy quotes file",
+			Components: []discordgo.MessageComponent{},
+		}
+	}
+
+	pages := (len(speakers) + speakersPerPage - 1) / speakersPerPage
+	page = max(0, min(page, pages-1))
+	first := page * speakersPerPage
+	last := min(first+speakersPerPage, len(speakers))
+
+	var content strings.Builder
+	_, _ = fmt.Fprintf(&content, "Choose the member each legacy speaker is (page %d/%d)\n", page+1, max(pages, 1))
+
+	var components []discordgo.MessageComponent
+	for index := first; index < last; index++ {
+		speaker := speakers[index]
+		mapping := "*unmapped*"
+		if len(speaker.NameMap.UserDiscordID) > 0 {
+			mapping = fmt.Sprintf("<@%s>", speaker.NameMap.UserDiscordID)
+		} else if len(speaker.NameMap.UserName) > 0 {
+			mapping = speaker.NameMap.UserName
+		}
+		_, _ = fmt.Fprintf(&content, "%d. **%s** (%d quotes) → %s\n", index+1, speaker.Name, speaker.Quotes, mapping)
+
+		components = append(components, discordgo.ActionsRow{
+			Components: []discordgo.MessageComponent{
+				discordgo.SelectMenu{
+					MenuType:    discordgo.UserSelectMenu,
+					CustomID:    makeCustomID(mapSpeakerComponent, index),
+					Placeholder: truncate(fmt.Sprintf("%d. %s", index+1, speaker.Name), 150),
+				},
+			},
+		})
+	}
+
+	components = append(components, discordgo.ActionsRow{
+		Components: []discordgo.MessageComponent{
+			discordgo.Button{
+				Label:    "Previous",
+				Style:    discordgo.SecondaryButton,
+				CustomID: makeCustomID(mapPageComponent, page-1),
+				Disabled: page == 0,
+			},
+			discordgo.Button{
+				Label:    "Next",
+				Style:    discordgo.SecondaryButton,
+				CustomID: makeCustomID(mapPageComponent, page+1),
+				Disabled: page >= pages-1,
+			},
+			discordgo.Button{
+				Label:    "Run migration",
+				Style:    discordgo.PrimaryButton,
+				CustomID: makeCustomID(mapRunComponent, ""),
+			},
+		},
+	})
+
+	return &discordgo.InteractionResponseData{
+		Content:    content.String(),
+		Components: components,
+	}
+}
+
+// speakerMapUpdate redraws the wizard message on the given page
+func speakerMapUpdate(job data.MigrationJob, page int) discordgo.InteractionResponse {
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: speakerMapPage(job, page),
+	}
+}
+
+func mapSpeakerComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	componentData := icEvent.MessageComponentData()
+	_, argument := splitCustomID(componentData.CustomID)
+	index, _ := strconv.Atoi(argument)
+
+	var response discordgo.InteractionResponse
+	job := manager.FindMigrationJob(icEvent.GuildID)
+	speakers, err := migration.LegacySpeakers(job)
+
+	if err != nil || index < 0 || index >= len(speakers) || len(componentData.Values) == 0 {
+		response = ephemeralResponse("Sorry, that speaker no longer exists, please run the command again")
+	} else {
+		discordUser := componentData.Resolved.Users[componentData.Values[0]]
+		if discordUser == nil {
+			discordUser = &discordgo.User{ID: componentData.Values[0]}
+		}
+
+		guild := manager.FindGuildEntry(icEvent.GuildID)
+		user := manager.AddUser(discordUser, guild)
+
+		manager.SetMigrationNameMap(job, speakers[index].Name, user)
+		response = speakerMapUpdate(manager.FindMigrationJob(icEvent.GuildID), index/speakersPerPage)
+	}
+
+	respond(session, icEvent.Interaction, response)
+}
+
+func mapPageComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	_, argument := splitCustomID(icEvent.MessageComponentData().CustomID)
+	page, _ := strconv.Atoi(argument)
+
+	response := speakerMapUpdate(manager.FindMigrationJob(icEvent.GuildID), page)
+
+	respond(session, icEvent.Interaction, response)
+}
+
+func mapRunComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	// the router has deferred the update, as the migration inserts quotes one by one
+	job, waiting := migration.RunMigration(manager, session, icEvent.GuildID)
+	dispatchNewQuotes(manager, session, waiting, icEvent.GuildID)
+
+	response := discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    migrationStatusResponse(job).Data.Content,
+			Components: []discordgo.MessageComponent{},
+		},
+	}
+
+	respond(session, icEvent.Interaction, response)
+}
+
+// truncate shortens text to at most limit characters, as Discord counts them, without splitting one
+func truncate(text string, limit int) string {
+	runes := []rune(text)
+	if len(runes) <= limit {
+		return text
+	}
+	return string(runes[:limit-3]) + "..."
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		text  string
+		limit int
+		want  string
+	}{
+		{name: "short", text: "quote", limit: 10, want: "quote"},
+		{name: "exact", text: "0123456789", limit: 10, want: "0123456789"},
+		{name: "long", text: "0123456789ab", limit: 10, want: "0123456..."},
+		{name: "multibyte within limit", text: "zoë ✨ 日本", limit: 8, want: "zoë ✨ 日本"},
+		{name: "multibyte cut", text: "日本語のクオートです", limit: 6, want: "日本語..."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := truncate(test.text, test.limit)
+			if got != test.want {
+				t.Errorf("truncate(%q, %d) = %q, want %q", test.text, test.limit, got, test.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("truncate(%q, %d) = %q, which is not valid UTF-8", test.text, test.limit, got)
+			}
+		})
+	}
+
+	if got := truncate(strings.Repeat("é", 2001), 2000); utf8.RuneCountInString(got) != 2000 {
+		t.Errorf("truncate of 2001 characters kept %d characters, want 2000", utf8.RuneCountInString(got))
+	}
+}
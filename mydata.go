@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+)
+
+func quoteMyDataHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, groupData *discordgo.ApplicationCommandInteractionDataOption) {
+	var response discordgo.InteractionResponse
+
+	switch groupData.Options[0].Name {
+	case myDataExport.Name:
+		response = myDataExportResponse(manager, session, interaction.Member.User)
+	case myDataErase.Name:
+		response = eraseConfirmationResponse(interaction.Member.User)
+	}
+
+	respond(session, interaction, response)
+}
+
+// myDataExportResponse DMs the user a JSON file of their data and reports how it went
+func myDataExportResponse(manager data.Manager, session *discordgo.Session, user *discordgo.User) discordgo.InteractionResponse {
+	export := manager.ExportUserData(user.ID)
+
+	exportJson, err := json.MarshalIndent(export, "", "\t")
+	if err != nil {
+		log.Printf("Error marshalling data export of %s: %v", user.Username, err)
+		return ephemeralResponse("Sorry, something went wrong while collecting your data")
+	}
+
+	channel, err := session.UserChannelCreate(user.ID)
+	if err == nil {
+		_, err = session.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+			Content: "Here is everything QuoteBot stores about you",
+			Files: []*discordgo.File{
+				{
+					Name:        fmt.Sprintf("quotebot-data-%s.json", user.ID),
+					ContentType: "application/json",
+					Reader:      bytes.NewReader(exportJson),
+				},
+			},
+		})
+	}
+	if err != nil {
+		log.Printf("Failed to send data export to %s: %v", user.Username, err)
+		return ephemeralResponse("Sorry, I couldn't DM you. Please allow direct messages from this server and try again")
+	}
+
+	return ephemeralResponse("I've sent you a direct message with your data")
+}
+
+func eraseDataComponentHandler(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) {
+	_, discordID := splitCustomID(icEvent.MessageComponentData().CustomID)
+
+	var response discordgo.InteractionResponse
+	if icEvent.Member == nil || icEvent.Member.User.ID != discordID {
+		response = ephemeralResponse("Sorry, you can only erase your own data")
+	} else {
+		erasedQuotes, erasedUsers, err := manager.EraseUserData(discordID)
+		if err != nil {
+			response = ephemeralResponse("Sorry, something went wrong while erasing your data, nothing was erased")
+		} else {
+			speakerLooks.forget(discordID)
+			response = eraseResultResponse(erasedQuotes, erasedUsers)
+		}
+	}
+
+	respond(session, icEvent.Interaction, response)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+)
+
+// defaultCapabilityPermissions are the Discord permissions that give a capability without a role being granted it
+var defaultCapabilityPermissions = map[data.Capability]int64{
+	data.AddCapability:       discordgo.PermissionManageMessages,
+	data.DeleteAnyCapability: discordgo.PermissionManageMessages,
+	data.EditAnyCapability:   discordgo.PermissionManageMessages,
+	data.ExportCapability:    discordgo.PermissionManageServer,
+	data.ConfigureCapability: discordgo.PermissionManageServer,
+}
+
+// memberHasCapability reports whether a member may do something in a guild
+// administrators may do anything, other members need the default permission or a role granted the capability
+func memberHasCapability(manager data.Manager, guild data.Guild, member *discordgo.Member, capability data.Capability) bool {
+	if member == nil {
+		return false
+	}
+
+	if capability == data.AddCapability && manager.FindGuildSettings(guild).WhoMayAdd() == data.EveryoneMayAdd {
+		return true
+	}
+
+	if hasPermission(member, defaultCapabilityPermissions[capability]) {
+		return true
+	}
+	return manager.RolesHaveCapability(guild, member.Roles, capability)
+}
+
+// requiredCapability is the capability an interaction needs, if it needs one
+func requiredCapability(icEvent *discordgo.InteractionCreate) (data.Capability, bool) {
+	switch icEvent.Type {
+	case discordgo.InteractionApplicationCommand:
+		commandData := icEvent.ApplicationCommandData()
+		switch commandData.Name {
+		case quoteThisMessageCommand.Name:
+			return data.AddCapability, true
+		case quoteSlashCommands.Name:
+			if len(commandData.Options) > 0 && commandData.Options[0].Name == quoteAdd.Name {
+				return data.AddCapability, true
+			}
+		case quoteAdminSlashCommands.Name:
+			if len(commandData.Options) > 0 && commandData.Options[0].Name == adminExport.Name {
+				return data.ExportCapability, true
+			}
+			return data.ConfigureCapability, true
+		}
+	case discordgo.InteractionMessageComponent:
+		action, _ := splitCustomID(icEvent.MessageComponentData().CustomID)
+		switch action {
+		case approveQuoteComponent:
+			return data.EditAnyCapability, true
+		case rejectQuoteComponent:
+			return data.DeleteAnyCapability, true
+		case mapSpeakerComponent, mapPageComponent, mapRunComponent:
+			return data.ConfigureCapability, true
+		}
+	}
+	return "", false
+}
+
+// authorizeInteraction checks an interaction before it is handled, answering it if the member isn't allowed
+func authorizeInteraction(manager data.Manager, session *discordgo.Session, icEvent *discordgo.InteractionCreate) bool {
+	capability, ok := requiredCapability(icEvent)
+	if !ok || memberHasCapability(manager, manager.FindGuildEntry(icEvent.GuildID), icEvent.Member, capability) {
+		return true
+	}
+
+	response := ephemeralResponse(fmt.Sprintf("Sorry, you need the %s permission to do that", capability))
+	respond(session, icEvent.Interaction, response)
+	return false
+}
+
+func adminPermissionsHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, groupData *discordgo.ApplicationCommandInteractionDataOption) {
+	guild := manager.FindGuildEntry(interaction.GuildID)
+
+	optionData := groupData.Options[0]
+	optionMap := makeOptionMap(optionData.Options)
+
+	var response discordgo.InteractionResponse
+	switch optionData.Name {
+	case permissionsGrant.Name:
+		role := optionMap["role"].RoleValue(nil, "")
+		capability := data.Capability(optionMap["capability"].StringValue())
+		if manager.GrantCapability(guild, role.ID, capability) {
+			response = ephemeralResponse(fmt.Sprintf("Members with <@&%s> now have the %s permission", role.ID, capability))
+		} else {
+			response = ephemeralResponse(fmt.Sprintf("<@&%s> already has the %s permission", role.ID, capability))
+		}
+	case permissionsRevoke.Name:
+		role := optionMap["role"].RoleValue(nil, "")
+		capability := data.Capability(optionMap["capability"].StringValue())
+		if manager.RevokeCapability(guild, role.ID, capability) {
+			response = ephemeralResponse(fmt.Sprintf("Members with <@&%s> no longer have the %s permission", role.ID, capability))
+		} else {
+			response = ephemeralResponse(fmt.Sprintf("<@&%s> didn't have the %s permission", role.ID, capability))
+		}
+	case permissionsList.Name:
+		response = rolePermissionsResponse(manager.FindRolePermissions(guild))
+	}
+
+	respond(session, interaction, response)
+}
@@ -2,7 +2,8 @@
 package main
 
 import (
-	"encoding/json"
+	"errors"
+	"flag"
 	"github.com/DeLucaJ/quotebot/internal/data"
 	"github.com/bwmarrin/discordgo"
 	"log"
@@ -11,15 +12,6 @@ import (
 	"syscall"
 )
 
-// location of configuration file
-const configFile string = "./config.json"
-
-// BotConfig internal struct for configuration management
-type BotConfig struct {
-	DiscordToken     string `json:"discord-token"`
-	ConnectionString string `json:"connection-string"`
-}
-
 // Used for general error checking and panicking
 func checkError(err error, message string) {
 	if err != nil {
@@ -28,25 +20,24 @@ func checkError(err error, message string) {
 	}
 }
 
-// Retrieves the Bot DiscordToken
-// Will eventually get the JSON configuration for quoteBot
-func getConfig(file string) BotConfig {
-	// read the fileData of file
-	fileData, err := os.ReadFile(file)
-	checkError(err, "Error reading discord token: ")
-
-	//turn config.json into a BotConfig struct
-	var configuration BotConfig
-	err = json.Unmarshal(fileData, &configuration)
-	checkError(err, "Error unmarshalling json: ")
-
-	return configuration
-}
-
 func main() {
 	// INITIALIZATION ---------------------------------------------------------
 	// Store the application configuration
-	botConfig := getConfig(configFile)
+	botConfig, args, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+
+	// Subcommands do their work without starting the bot
+	if runSubcommand(botConfig, args) {
+		return
+	}
+
+	if err = botConfig.require("discord-token", "connection-string"); err != nil {
+		log.Fatal(err)
+	}
 
 	// Starts the data manager for the bot
 	botManager := data.Start(botConfig.ConnectionString)
@@ -57,24 +48,38 @@ func main() {
 	session, err := discordgo.New("Bot " + botConfig.DiscordToken)
 	checkError(err, "Error creating Discord Session: ")
 
-	// a map of registered commands by server ID
-	var commandMap = make(map[string][]string)
-
 	// EVENT HANDLING ---------------------------------------------------------
 	// Define Handlers for discord events.
-	guildCreate := guildCreateHandler(botManager, commandMap)
+	guildCreate := guildCreateHandler(botManager, botConfig)
 	guildUpdate := guildUpdateHandler(botManager)
 	memberAdd := memberAddHandler(botManager)
 	memberUpdate := memberUpdateHandler(botManager)
-	interactionCreate := interactionCreateHandler(botManager)
+	limits := newRateLimits(botConfig)
+	interactionCreate := interactionCreateHandler(botManager, limits)
+	messageCreate := messageCreateHandler(botManager, limits)
+	memberRemove := memberRemoveHandler(botManager)
+	guildDelete := guildDeleteHandler(botManager, botConfig.GuildRetention)
 
 	// Attach Handlers to the discord session
-	session.AddHandler(ready)
+	session.AddHandler(readyHandler(botConfig))
 	session.AddHandler(guildCreate)
 	session.AddHandler(guildUpdate)
 	session.AddHandler(memberAdd)
 	session.AddHandler(memberUpdate)
 	session.AddHandler(interactionCreate)
+	session.AddHandler(messageCreate)
+	session.AddHandler(memberRemove)
+	session.AddHandler(guildDelete)
+
+	// privileged intents are only requested when configured, Discord refuses the connection
+	// if the bot hasn't been allowed them; the features that need them are left out otherwise
+	session.Identify.Intents |= botConfig.intents()
+	if !botConfig.MembersIntent {
+		log.Println("The members intent is off: members are only recorded when they use QuoteBot")
+	}
+	if !botConfig.ContentIntent {
+		log.Println("The message content intent is off: the legacy !quote commands won't work")
+	}
 
 	// START SESSION ----------------------------------------------------------
 	// Open Discord session
@@ -82,15 +87,17 @@ func main() {
 	checkError(err, "Error opening Discord session: ")
 
 	// Defers a call to Close the Discord Session
+	// registered commands are left in place so they keep working across restarts
 	defer func(ds *discordgo.Session) {
-		for guildID, commandIDs := range commandMap {
-			removeAllCommands(ds, guildID, commandIDs)
-		}
-
 		err := ds.Close()
 		checkError(err, "Error closing Discord session: ")
 	}(session)
 
+	// Purges guilds that removed the bot once their retention period is over
+	stopPurges := make(chan struct{})
+	defer close(stopPurges)
+	go schedulePurges(botManager, botConfig.GuildRetention, stopPurges)
+
 	// Start Message
 	log.Println("Welcome to QuoteBot X. Press CTRL+C to exit.")
 
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"log"
+	"maps"
+	"slices"
+)
+
+// guildCommands - the commands a guild should have registered to itself
+// with the global scope a guild has none of its own, which clears any left over from the guild scope
+func guildCommands(config BotConfig, guildID string) []*discordgo.ApplicationCommand {
+	if config.CommandScope != guildCommandScope {
+		return []*discordgo.ApplicationCommand{}
+	}
+	if len(config.CommandGuilds) == 0 || slices.Contains(config.CommandGuilds, guildID) {
+		return allCommands
+	}
+	return []*discordgo.ApplicationCommand{}
+}
+
+// globalCommands - the commands registered for every guild at once
+// with the guild scope there are none, which clears any left over from the global scope
+func globalCommands(config BotConfig) []*discordgo.ApplicationCommand {
+	if config.CommandScope == globalCommandScope {
+		return allCommands
+	}
+	return []*discordgo.ApplicationCommand{}
+}
+
+// syncCommands - makes the commands registered for a guild match the given ones
+// an empty guildID syncs the global commands; nothing is sent when the registered commands are already current
+func syncCommands(session *discordgo.Session, applicationID string, guildID string, commands []*discordgo.ApplicationCommand) error {
+	existing, err := session.ApplicationCommands(applicationID, guildID)
+	if err != nil {
+		return fmt.Errorf("listing commands: %w", err)
+	}
+
+	if sameCommands(existing, commands) {
+		return nil
+	}
+
+	log.Println("Registering commands...")
+	_, err = session.ApplicationCommandBulkOverwrite(applicationID, guildID, commands)
+	if err != nil {
+		return fmt.Errorf("overwriting commands: %w", err)
+	}
+	return nil
+}
+
+// sameCommands reports whether the registered commands have the same definitions as the wanted ones
+func sameCommands(registered []*discordgo.ApplicationCommand, wanted []*discordgo.ApplicationCommand) bool {
+	if len(registered) != len(wanted) {
+		return false
+	}
+
+	byName := make(map[string]*discordgo.ApplicationCommand, len(registered))
+	for _, command := range registered {
+		byName[commandKey(command)] = command
+	}
+
+	for _, command := range wanted {
+		other, ok := byName[commandKey(command)]
+		if !ok || !sameCommand(other, command) {
+			return false
+		}
+	}
+	return true
+}
+
+// commandKey identifies a command, names are only unique per command type
+func commandKey(command *discordgo.ApplicationCommand) string {
+	return fmt.Sprintf("%d:%s", commandType(command), command.Name)
+}
+
+// commandType is the type of command, Discord treats an unset type as a chat command
+func commandType(command *discordgo.ApplicationCommand) discordgo.ApplicationCommandType {
+	if command.Type == 0 {
+		return discordgo.ChatApplicationCommand
+	}
+	return command.Type
+}
+
+func sameCommand(a *discordgo.ApplicationCommand, b *discordgo.ApplicationCommand) bool {
+	return commandType(a) == commandType(b) &&
+		a.Name == b.Name &&
+		a.Description == b.Description &&
+		sameLocalizations(a.NameLocalizations, b.NameLocalizations) &&
+		sameLocalizations(a.DescriptionLocalizations, b.DescriptionLocalizations) &&
+		samePermissions(a.DefaultMemberPermissions, b.DefaultMemberPermissions) &&
+		sameDMPermission(a.DMPermission, b.DMPermission) &&
+		sameOptions(a.Options, b.Options)
+}
+
+func samePermissions(a *int64, b *int64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// sameDMPermission compares whether commands are offered in DMs, which Discord leaves out for guild commands
+func sameDMPermission(a *bool, b *bool) bool {
+	return a == nil || b == nil || *a == *b
+}
+
+// sameLocalizations compares the translations of a command's name or description, where none at all is the same as an empty set
+func sameLocalizations(a *map[discordgo.Locale]string, b *map[discordgo.Locale]string) bool {
+	var first, second map[discordgo.Locale]string
+	if a != nil {
+		first = *a
+	}
+	if b != nil {
+		second = *b
+	}
+	return maps.Equal(first, second)
+}
+
+// sameBound compares an optional minimum of an option
+func sameBound[T comparable](a *T, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func sameOptions(a []*discordgo.ApplicationCommandOption, b []*discordgo.ApplicationCommandOption) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for index := range a {
+		if !sameOption(a[index], b[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameOption(a *discordgo.ApplicationCommandOption, b *discordgo.ApplicationCommandOption) bool {
+	if a.Type != b.Type || a.Name != b.Name || a.Description != b.Description ||
+		!maps.Equal(a.NameLocalizations, b.NameLocalizations) ||
+		!maps.Equal(a.DescriptionLocalizations, b.DescriptionLocalizations) ||
+		a.Required != b.Required || a.Autocomplete != b.Autocomplete ||
+		!sameBound(a.MinValue, b.MinValue) || a.MaxValue != b.MaxValue ||
+		!sameBound(a.MinLength, b.MinLength) || a.MaxLength != b.MaxLength ||
+		len(a.ChannelTypes) != len(b.ChannelTypes) || len(a.Choices) != len(b.Choices) {
+		return false
+	}
+
+	for index := range a.ChannelTypes {
+		if a.ChannelTypes[index] != b.ChannelTypes[index] {
+			return false
+		}
+	}
+
+	// choice values come back from Discord as JSON numbers or strings, so they are compared as text
+	for index := range a.Choices {
+		if a.Choices[index].Name != b.Choices[index].Name ||
+			!maps.Equal(a.Choices[index].NameLocalizations, b.Choices[index].NameLocalizations) ||
+			fmt.Sprint(a.Choices[index].Value) != fmt.Sprint(b.Choices[index].Value) {
+			return false
+		}
+	}
+	return sameOptions(a.Options, b.Options)
+}
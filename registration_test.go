@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestCommandsByScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     BotConfig
+		guildID    string
+		wantGlobal int
+		wantGuild  int
+	}{
+		{name: "global scope", config: BotConfig{CommandScope: globalCommandScope}, guildID: "1", wantGlobal: len(allCommands), wantGuild: 0},
+		{name: "guild scope", config: BotConfig{CommandScope: guildCommandScope}, guildID: "1", wantGlobal: 0, wantGuild: len(allCommands)},
+		{name: "guild scope, listed guild", config: BotConfig{CommandScope: guildCommandScope, CommandGuilds: []string{"1"}}, guildID: "1", wantGlobal: 0, wantGuild: len(allCommands)},
+		{name: "guild scope, other guild", config: BotConfig{CommandScope: guildCommandScope, CommandGuilds: []string{"1"}}, guildID: "2", wantGlobal: 0, wantGuild: 0},
+	}
+
+	for _, test := range tests {
+		global, guild := globalCommands(test.config), guildCommands(test.config, test.guildID)
+		// an empty list rather than nil, so syncing it clears the commands of the other scope
+		if global == nil || guild == nil || len(global) != test.wantGlobal || len(guild) != test.wantGuild {
+			t.Errorf("%s: %d global and %d guild commands, want %d and %d", test.name, len(global), len(guild), test.wantGlobal, test.wantGuild)
+		}
+	}
+}
+
+// optionCommand is a chat command with a single string option changed by change
+func optionCommand(change func(option *discordgo.ApplicationCommandOption)) *discordgo.ApplicationCommand {
+	minLength := 1
+	option := &discordgo.ApplicationCommandOption{
+		Type:        discordgo.ApplicationCommandOptionString,
+		Name:        "text",
+		Description: "the text",
+		MinLength:   &minLength,
+		MaxLength:   100,
+		Choices: []*discordgo.ApplicationCommandOptionChoice{
+			{Name: "one", Value: 1},
+		},
+	}
+	if change != nil {
+		change(option)
+	}
+	return &discordgo.ApplicationCommand{Name: "quote", Description: "quotes", Options: []*discordgo.ApplicationCommandOption{option}}
+}
+
+func TestSameCommands(t *testing.T) {
+	otherMinLength := 2
+	localized := map[discordgo.Locale]string{discordgo.German: "zitat"}
+
+	tests := []struct {
+		name       string
+		registered *discordgo.ApplicationCommand
+		want       bool
+	}{
+		{name: "unchanged", registered: optionCommand(nil), want: true},
+		{name: "choice value read back as a JSON number", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.Choices[0].Value = float64(1)
+		}), want: true},
+		{name: "no localizations and empty ones", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.NameLocalizations = map[discordgo.Locale]string{}
+		}), want: true},
+		{name: "minimum length", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.MinLength = &otherMinLength
+		}), want: false},
+		{name: "no minimum length", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.MinLength = nil
+		}), want: false},
+		{name: "maximum length", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.MaxLength = 50
+		}), want: false},
+		{name: "option name localization", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.NameLocalizations = localized
+		}), want: false},
+		{name: "option description localization", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.DescriptionLocalizations = localized
+		}), want: false},
+		{name: "choice localization", registered: optionCommand(func(option *discordgo.ApplicationCommandOption) {
+			option.Choices[0].NameLocalizations = localized
+		}), want: false},
+		{name: "command localization", registered: func() *discordgo.ApplicationCommand {
+			command := optionCommand(nil)
+			command.NameLocalizations = &localized
+			return command
+		}(), want: false},
+	}
+
+	for _, test := range tests {
+		registered := []*discordgo.ApplicationCommand{test.registered}
+		wanted := []*discordgo.ApplicationCommand{optionCommand(nil)}
+		if got := sameCommands(registered, wanted); got != test.want {
+			t.Errorf("%s: sameCommands = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
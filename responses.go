@@ -4,17 +4,53 @@ import (
 	"fmt"
 	"github.com/DeLucaJ/quotebot/internal/data"
 	"github.com/bwmarrin/discordgo"
+	"strings"
 	"time"
 )
 
-func getQuotesResponse(session *discordgo.Session, quotes []data.Quote) discordgo.InteractionResponse {
-	if quotes[0].SpeakerID == 0 {
+func getQuotesResponse(session *discordgo.Session, quotes []data.Quote, private bool) discordgo.InteractionResponse {
+	if len(quotes) == 0 || quotes[0].SpeakerID == 0 {
 		return emptyResponse("Sorry, there are no quotes matching your search")
+	} else if private {
+		return privateQuotesResponse(session, quotes)
 	} else {
 		return multiQuoteResponse(session, quotes)
 	}
 }
 
+// privateQuotesResponse shows quotes only to the member who asked, with a button to share each of them
+func privateQuotesResponse(session *discordgo.Session, quotes []data.Quote) discordgo.InteractionResponse {
+	response := multiQuoteResponse(session, quotes)
+	response.Data.Flags = discordgo.MessageFlagsEphemeral
+
+	// a row holds at most 5 buttons
+	var row discordgo.ActionsRow
+	for index, quote := range quotes {
+		label := "Share"
+		if len(quotes) > 1 {
+			label = fmt.Sprintf("Share %d", index+1)
+		}
+		row.Components = append(row.Components, discordgo.Button{
+			Label:    label,
+			Style:    discordgo.SecondaryButton,
+			CustomID: makeCustomID(shareQuoteComponent, quote.ID),
+		})
+		if len(row.Components) == 5 || index == len(quotes)-1 {
+			response.Data.Components = append(response.Data.Components, row)
+			row = discordgo.ActionsRow{}
+		}
+	}
+	return response
+}
+
+// sharedQuoteResponse posts a quote found privately for everyone in the channel
+func sharedQuoteResponse(session *discordgo.Session, quote data.Quote, sharer *discordgo.User) discordgo.InteractionResponse {
+	response := singleQuoteResponse(session, quote)
+	response.Data.Content = fmt.Sprintf("Shared by <@%s>", sharer.ID)
+	response.Data.AllowedMentions = &discordgo.MessageAllowedMentions{}
+	return response
+}
+
 func multiQuoteResponse(session *discordgo.Session, quotes []data.Quote) discordgo.InteractionResponse {
 	var quoteEmbeds = make([]*discordgo.MessageEmbed, len(quotes))
 
@@ -33,11 +69,77 @@ func multiQuoteResponse(session *discordgo.Session, quotes []data.Quote) discord
 func addQuoteResponse(session *discordgo.Session, quote data.Quote) discordgo.InteractionResponse {
 	if quote.SpeakerID == 0 {
 		return emptyResponse(quote.Content)
+	} else if quote.Status == data.QuotePending {
+		return ephemeralResponse("Thanks! Your quote has been sent to the moderators for approval")
+	} else if quote.Status == data.QuoteConsent {
+		return ephemeralResponse(fmt.Sprintf("Thanks! %s has asked to approve quotes about them, so I've sent it to them first", quote.Speaker.DisplayName(quote.Guild.Settings.Names())))
 	} else {
 		return singleQuoteResponse(session, quote)
 	}
 }
 
+// heldQuoteResponse shows the submitter the existing quote their new one resembles and asks whether to add it anyway
+func heldQuoteResponse(session *discordgo.Session, quote data.Quote, similar data.Quote) discordgo.InteractionResponse {
+	content := fmt.Sprintf("%s already has a quote like this one. Add yours anyway?", quote.Speaker.DisplayName(quote.Guild.Settings.Names()))
+	var embeds []*discordgo.MessageEmbed
+	if similar.ID != 0 {
+		embeds = append(embeds, quoteToEmbed(session, similar))
+	}
+
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Embeds:  embeds,
+			Flags:   discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Add anyway",
+							Style:    discordgo.PrimaryButton,
+							CustomID: makeCustomID(confirmHeldComponent, quote.ID),
+						},
+						discordgo.Button{
+							Label:    "Cancel",
+							Style:    discordgo.SecondaryButton,
+							CustomID: makeCustomID(cancelHeldComponent, quote.ID),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// heldDecisionResponse replaces the question about a held quote with the submitter's answer
+func heldDecisionResponse(session *discordgo.Session, quote data.Quote, confirmed bool) discordgo.InteractionResponse {
+	var content string
+	embeds := []*discordgo.MessageEmbed{}
+	switch {
+	case quote.ID == 0:
+		content = "Sorry, that quote no longer exists"
+	case !confirmed:
+		content = "Okay, your quote wasn't added"
+	case quote.Status == data.QuotePending:
+		content = "Thanks! Your quote has been sent to the moderators for approval"
+	case quote.Status == data.QuoteConsent:
+		content = fmt.Sprintf("Thanks! %s has asked to approve quotes about them, so I've sent it to them first", quote.Speaker.DisplayName(quote.Guild.Settings.Names()))
+	default:
+		content = "Thanks! The quote has been added"
+		embeds = append(embeds, quoteToEmbed(session, quote))
+	}
+
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    content,
+			Embeds:     embeds,
+			Components: []discordgo.MessageComponent{},
+		},
+	}
+}
+
 func singleQuoteResponse(session *discordgo.Session, quote data.Quote) discordgo.InteractionResponse {
 	if quote.SpeakerID == 0 {
 		return emptyResponse("Sorry, there are no quotes matching your search")
@@ -64,21 +166,242 @@ func emptyResponse(content string) discordgo.InteractionResponse {
 	}
 }
 
+func ephemeralResponse(content string) discordgo.InteractionResponse {
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	}
+}
+
+func approvalSettingsResponse(settings data.GuildSettings) discordgo.InteractionResponse {
+	return ephemeralResponse(approvalDescription(settings))
+}
+
+func approvalDescription(settings data.GuildSettings) string {
+	if !settings.RequireApproval {
+		return "New quotes will be added without moderator approval"
+	}
+	return fmt.Sprintf("New quotes will be posted to <#%s> for approval", settings.ApprovalChannelID)
+}
+
+func announcementSettingsResponse(settings data.GuildSettings) discordgo.InteractionResponse {
+	return ephemeralResponse(announcementDescription(settings))
+}
+
+func announcementDescription(settings data.GuildSettings) string {
+	if settings.MuteAnnouncements {
+		return "QuoteBot won't announce itself when it starts"
+	} else if len(settings.AnnounceChannelID) == 0 {
+		return "QuoteBot will announce itself in the system channel when it starts"
+	}
+	return fmt.Sprintf("QuoteBot will announce itself in <#%s> when it starts", settings.AnnounceChannelID)
+}
+
+func whoCanAddDescription(settings data.GuildSettings) string {
+	switch settings.WhoMayAdd() {
+	case data.ModeratorsMayAdd:
+		return "Only moderators and roles granted the add permission can add quotes"
+	}
+	return "Everyone can add quotes"
+}
+
+func legacyCommandsDescription(settings data.GuildSettings) string {
+	if settings.LegacyCommands {
+		return "QuoteBot will answer the legacy !quote commands"
+	}
+	return "QuoteBot won't answer the legacy !quote commands"
+}
+
+// guildSettingsResponse lists every setting of a guild
+func guildSettingsResponse(settings data.GuildSettings) discordgo.InteractionResponse {
+	content := fmt.Sprintf("**Max amount:** %d quotes per command\n**Announcements:** %s\n"+
+		"**Embed style:** %s\n**Names:** %s\n**Approval:** %s\n**Who can add:** %s\n**Legacy commands:** %s\n**Rate limits:**",
+		settings.QuoteLimit(), announcementDescription(settings), settings.Style(), settings.Names(),
+		approvalDescription(settings), whoCanAddDescription(settings), legacyCommandsDescription(settings))
+	for _, scope := range data.RateLimitScopes {
+		limit := settings.RateLimit(scope)
+		if len(limit) == 0 {
+			limit = "default"
+		}
+		content += fmt.Sprintf(" per %s %s", scope, limit)
+	}
+	return ephemeralResponse(content)
+}
+
+// approvalRequestMessage builds the moderator message for a pending quote
+func approvalRequestMessage(session *discordgo.Session, quote data.Quote) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: "A new quote is waiting for approval",
+		Embeds: []*discordgo.MessageEmbed{
+			quoteToEmbed(session, quote),
+		},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Approve",
+						Style:    discordgo.SuccessButton,
+						CustomID: makeCustomID(approveQuoteComponent, quote.ID),
+					},
+					discordgo.Button{
+						Label:    "Reject",
+						Style:    discordgo.DangerButton,
+						CustomID: makeCustomID(rejectQuoteComponent, quote.ID),
+					},
+				},
+			},
+		},
+	}
+}
+
+// approvalDecisionResponse replaces an approval request with the moderator's decision
+func approvalDecisionResponse(session *discordgo.Session, quote data.Quote, moderator *discordgo.User) discordgo.InteractionResponse {
+	if quote.ID == 0 {
+		return ephemeralResponse("Sorry, that quote no longer exists")
+	}
+
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: fmt.Sprintf("Quote %s by %s", quote.Status, moderator.Username),
+			Embeds: []*discordgo.MessageEmbed{
+				quoteToEmbed(session, quote),
+			},
+			Components: []discordgo.MessageComponent{},
+		},
+	}
+}
+
+func privacySettingsResponse(user data.User) discordgo.InteractionResponse {
+	if user.OptOut {
+		return ephemeralResponse("You have opted out: new quotes about you will be refused")
+	} else if user.RequireConsent {
+		return ephemeralResponse("New quotes about you will be sent to you for consent before they are added")
+	}
+	return ephemeralResponse("Anyone can quote you in this server")
+}
+
+// consentRequestMessage builds the DM asking a speaker to consent to a quote
+func consentRequestMessage(session *discordgo.Session, quote data.Quote) *discordgo.MessageSend {
+	return &discordgo.MessageSend{
+		Content: fmt.Sprintf("%s would like to quote you. Is that okay?", quote.Submitter.DisplayName(quote.Guild.Settings.Names())),
+		Embeds: []*discordgo.MessageEmbed{
+			quoteToEmbed(session, quote),
+		},
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{
+				Components: []discordgo.MessageComponent{
+					discordgo.Button{
+						Label:    "Accept",
+						Style:    discordgo.SuccessButton,
+						CustomID: makeCustomID(acceptConsentComponent, quote.ID),
+					},
+					discordgo.Button{
+						Label:    "Decline",
+						Style:    discordgo.DangerButton,
+						CustomID: makeCustomID(declineConsentComponent, quote.ID),
+					},
+				},
+			},
+		},
+	}
+}
+
+// consentDecisionResponse replaces a consent request with the speaker's answer
+func consentDecisionResponse(session *discordgo.Session, quote data.Quote) discordgo.InteractionResponse {
+	var content string
+	switch quote.Status {
+	case data.QuoteDeclined:
+		content = "You declined this quote, it won't be added"
+	case data.QuotePending:
+		content = "Thanks! The quote has been sent to the moderators for approval"
+	default:
+		content = "Thanks! The quote has been added"
+	}
+
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content: content,
+			Embeds: []*discordgo.MessageEmbed{
+				quoteToEmbed(session, quote),
+			},
+			Components: []discordgo.MessageComponent{},
+		},
+	}
+}
+
+// eraseConfirmationResponse asks a user to confirm erasing their data
+func eraseConfirmationResponse(user *discordgo.User) discordgo.InteractionResponse {
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: "This permanently deletes every quote you spoke in every server, forgets the names you went by, " +
+				"and replaces your name on quotes you submitted with \"" + data.ErasedUserName + "\". This can't be undone.",
+			Flags: discordgo.MessageFlagsEphemeral,
+			Components: []discordgo.MessageComponent{
+				discordgo.ActionsRow{
+					Components: []discordgo.MessageComponent{
+						discordgo.Button{
+							Label:    "Erase my data",
+							Style:    discordgo.DangerButton,
+							CustomID: makeCustomID(eraseDataComponent, user.ID),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func eraseResultResponse(erasedQuotes int64, erasedUsers int64) discordgo.InteractionResponse {
+	return discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseUpdateMessage,
+		Data: &discordgo.InteractionResponseData{
+			Content:    fmt.Sprintf("Done. Deleted %d quotes and anonymized %d profiles", erasedQuotes, erasedUsers),
+			Components: []discordgo.MessageComponent{},
+		},
+	}
+}
+
+func migrationStatusResponse(job data.MigrationJob) discordgo.InteractionResponse {
+	if job.ID == 0 {
+		return ephemeralResponse("This server has no legacy quote migration")
+	}
+
+	content := fmt.Sprintf("Legacy migration is **%s**: %d imported, %d duplicates, %d empty, %d unmatched, %d opted out, %d failed",
+		job.Status, job.Imported, job.Duplicates, job.Empty, job.Unmatched, job.OptedOut, job.Failed)
+	if job.Status == data.MigrationFailed {
+		content += fmt.Sprintf("\nError: %s", job.Error)
+	}
+	return ephemeralResponse(content)
+}
+
 func quoteToEmbed(session *discordgo.Session, quote data.Quote) *discordgo.MessageEmbed {
+	if quote.Guild.Settings.Style() == data.CompactEmbed {
+		return &discordgo.MessageEmbed{
+			Type:        discordgo.EmbedTypeRich,
+			Description: fmt.Sprintf("\"%s\"\n- %s", quote.Content, quote.Speaker.DisplayName(quote.Guild.Settings.Names())),
+		}
+	}
+
 	footer := discordgo.MessageEmbedFooter{
-		Text: fmt.Sprintf("Submitted by %s", quote.Submitter.Name),
+		Text: fmt.Sprintf("Submitted by %s", quote.Submitter.DisplayName(quote.Guild.Settings.Names())),
 	}
 
-	speakerInfo, _ := session.User(quote.Speaker.DiscordID)
+	look := lookupSpeaker(session, quote.Speaker, quote.Guild.DiscordID)
 
 	thumbnail := discordgo.MessageEmbedThumbnail{
-		URL: speakerInfo.AvatarURL(""),
+		URL: look.avatarURL,
 	}
 
 	embed := discordgo.MessageEmbed{
 		Type:        discordgo.EmbedTypeRich,
-		Color:       speakerInfo.AccentColor,
-		Title:       fmt.Sprintf("%s", quote.Speaker.Name),
+		Color:       look.accentColor,
+		Title:       quote.Speaker.DisplayName(quote.Guild.Settings.Names()),
 		Description: fmt.Sprintf("\"%s\"", quote.Content),
 		Footer:      &footer,
 		Thumbnail:   &thumbnail,
@@ -86,3 +409,58 @@ func quoteToEmbed(session *discordgo.Session, quote data.Quote) *discordgo.Messa
 	}
 	return &embed
 }
+
+// rolePermissionsResponse lists the capabilities granted to each role
+func rolePermissionsResponse(permissions []data.RolePermission) discordgo.InteractionResponse {
+	if len(permissions) == 0 {
+		return ephemeralResponse("No roles have been granted permissions, so moderators and admins decide everything")
+	}
+
+	var content strings.Builder
+	content.WriteString("**Role permissions**")
+	roleID := ""
+	for _, permission := range permissions {
+		if permission.RoleID != roleID {
+			roleID = permission.RoleID
+			content.WriteString(fmt.Sprintf("\n<@&%s>: %s", roleID, permission.Capability))
+		} else {
+			content.WriteString(fmt.Sprintf(", %s", permission.Capability))
+		}
+	}
+	return ephemeralResponse(content.String())
+}
+
+// channelRulesResponse lists the allowlist and denylist of a guild and how quote commands are answered elsewhere
+func channelRulesResponse(rules data.ChannelRules, settings data.GuildSettings) discordgo.InteractionResponse {
+	if len(rules) == 0 {
+		return ephemeralResponse("Quotes can be posted in every channel")
+	}
+
+	var allowed, denied []string
+	for _, rule := range rules {
+		if rule.Allow {
+			allowed = append(allowed, fmt.Sprintf("<#%s>", rule.ChannelID))
+		} else {
+			denied = append(denied, fmt.Sprintf("<#%s>", rule.ChannelID))
+		}
+	}
+
+	var content strings.Builder
+	if len(allowed) > 0 {
+		content.WriteString("**Allowed:** " + strings.Join(allowed, ", ") + "\n")
+	}
+	if len(denied) > 0 {
+		content.WriteString("**Denied:** " + strings.Join(denied, ", ") + "\n")
+	}
+
+	redirectChannelID := settings.RedirectChannelID
+	if len(redirectChannelID) == 0 && len(rules.Allowed()) > 0 {
+		redirectChannelID = rules.Allowed()[0]
+	}
+	if settings.Outside() == data.RedirectOutside && len(redirectChannelID) > 0 {
+		content.WriteString(fmt.Sprintf("Elsewhere, answers are posted in <#%s>", redirectChannelID))
+	} else {
+		content.WriteString("Elsewhere, answers are only shown to the member who asked")
+	}
+	return ephemeralResponse(content.String())
+}
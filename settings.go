@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/DeLucaJ/quotebot/internal/ratelimit"
+	"github.com/bwmarrin/discordgo"
+	"strings"
+)
+
+func adminSettingsHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction, groupData *discordgo.ApplicationCommandInteractionDataOption) {
+	guild := manager.FindGuildEntry(interaction.GuildID)
+	settings := manager.FindGuildSettings(guild)
+
+	optionData := groupData.Options[0]
+	optionMap := makeOptionMap(optionData.Options)
+
+	var response discordgo.InteractionResponse
+	switch optionData.Name {
+	case settingsView.Name:
+		response = guildSettingsResponse(settings)
+	case settingsMaxAmount.Name:
+		settings.MaxAmount = clampAmount(int(optionMap["amount"].IntValue()), data.MaxAmountLimit)
+		settings = manager.SaveGuildSettings(settings)
+		response = ephemeralResponse(fmt.Sprintf("Commands will send at most %d quotes", settings.QuoteLimit()))
+	case settingsAnnouncements.Name:
+		settings.MuteAnnouncements = !optionMap["enabled"].BoolValue()
+		if channelOption, ok := optionMap["channel"]; ok {
+			settings.AnnounceChannelID = channelOption.ChannelValue(nil).ID
+		}
+		settings = manager.SaveGuildSettings(settings)
+		response = announcementSettingsResponse(settings)
+	case settingsEmbedStyle.Name:
+		settings.EmbedStyle = data.EmbedStyle(optionMap["style"].StringValue())
+		settings = manager.SaveGuildSettings(settings)
+		response = ephemeralResponse(fmt.Sprintf("Quotes will now be shown in the %s style", settings.Style()))
+	case adminApproval.Name:
+		response = approvalSettingsHandler(manager, settings, optionMap)
+	case settingsWhoCanAdd.Name:
+		response = whoCanAddSettingsHandler(manager, settings, optionMap)
+	case settingsRateLimit.Name:
+		response = rateLimitSettingsHandler(manager, settings, optionMap)
+	case settingsLegacyCommands.Name:
+		response = legacyCommandsSettingsHandler(manager, session, settings, optionMap)
+	case settingsNameDisplay.Name:
+		settings.NameDisplay = data.NameDisplay(optionMap["style"].StringValue())
+		settings = manager.SaveGuildSettings(settings)
+		response = ephemeralResponse(fmt.Sprintf("Quotes will now show each speaker's %s", settings.Names()))
+	}
+
+	respond(session, interaction, response)
+}
+
+func approvalSettingsHandler(manager data.Manager, settings data.GuildSettings, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) discordgo.InteractionResponse {
+	required := optionMap["enabled"].BoolValue()
+	if channelOption, ok := optionMap["channel"]; ok {
+		settings.ApprovalChannelID = channelOption.ChannelValue(nil).ID
+	}
+
+	if required && len(settings.ApprovalChannelID) == 0 {
+		return ephemeralResponse("Please choose a channel for pending quotes to be posted to")
+	}
+
+	settings.RequireApproval = required
+	return approvalSettingsResponse(manager.SaveGuildSettings(settings))
+}
+
+func whoCanAddSettingsHandler(manager data.Manager, settings data.GuildSettings, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) discordgo.InteractionResponse {
+	settings.AddPermission = data.AddPermission(optionMap["who"].StringValue())
+	return ephemeralResponse(whoCanAddDescription(manager.SaveGuildSettings(settings)))
+}
+
+func legacyCommandsSettingsHandler(manager data.Manager, session *discordgo.Session, settings data.GuildSettings, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) discordgo.InteractionResponse {
+	settings.LegacyCommands = optionMap["enabled"].BoolValue()
+	settings = manager.SaveGuildSettings(settings)
+
+	if settings.LegacyCommands && !hasIntent(session, discordgo.IntentMessageContent) {
+		return ephemeralResponse("QuoteBot will answer the legacy !quote commands " +
+			"once its host turns on the message content intent")
+	}
+	return ephemeralResponse(legacyCommandsDescription(settings))
+}
+
+func rateLimitSettingsHandler(manager data.Manager, settings data.GuildSettings, optionMap map[string]*discordgo.ApplicationCommandInteractionDataOption) discordgo.InteractionResponse {
+	scope := data.RateLimitScope(optionMap["scope"].StringValue())
+	limit := strings.TrimSpace(optionMap["limit"].StringValue())
+
+	if limit == "default" {
+		settings.SetRateLimit(scope, "")
+		manager.SaveGuildSettings(settings)
+		return ephemeralResponse(fmt.Sprintf("The %s rate limit is back to QuoteBot's default", scope))
+	}
+
+	rule, err := ratelimit.ParseRule(limit)
+	if err != nil {
+		return ephemeralResponse(fmt.Sprintf("Sorry, I don't understand that limit: %v", err))
+	}
+
+	settings.SetRateLimit(scope, rule.String())
+	manager.SaveGuildSettings(settings)
+	return ephemeralResponse(fmt.Sprintf("The %s rate limit is now %s", scope, rule))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"github.com/DeLucaJ/quotebot/internal/data"
+	"github.com/bwmarrin/discordgo"
+	"log"
+)
+
+// members fetched per request, the most Discord allows
+const memberPageSize = 1000
+
+// syncGuildMembers pages through every member of a guild, upserting a page at a time
+// progress, if given, is called with the running total after every page
+func syncGuildMembers(manager data.Manager, session *discordgo.Session, guild data.Guild, progress func(synced int)) (int, error) {
+	synced := 0
+	after := "0"
+
+	for {
+		members, err := session.GuildMembers(guild.DiscordID, after, memberPageSize)
+		if err != nil {
+			return synced, err
+		}
+
+		synced += manager.UpsertMembers(members, guild)
+		if progress != nil {
+			progress(synced)
+		}
+
+		if len(members) < memberPageSize {
+			return synced, nil
+		}
+		after = members[len(members)-1].User.ID
+	}
+}
+
+func adminSyncMembersHandler(manager data.Manager, session *discordgo.Session, interaction *discordgo.Interaction) {
+	if !hasIntent(session, discordgo.IntentGuildMembers) {
+		respond(session, interaction, ephemeralResponse("Sorry, I can only list members once QuoteBot's host turns on the members intent"))
+		return
+	}
+
+	// large guilds take several requests, each page edits the deferred response with the progress so far
+	guild := manager.FindGuildEntry(interaction.GuildID)
+	_, deferred := deferrals.Load(interaction.ID)
+	synced, err := syncGuildMembers(manager, session, guild, func(synced int) {
+		if deferred {
+			edit := contentEdit(fmt.Sprintf("Syncing members... %d so far", synced))
+			_, _ = session.InteractionResponseEdit(interaction, &edit)
+		}
+	})
+
+	response := ephemeralResponse(fmt.Sprintf("Synced %d members", synced))
+	if err != nil {
+		log.Printf("Failed to sync members of %s: %v", guild.Name, err)
+		response = ephemeralResponse(fmt.Sprintf("Synced %d members before Discord refused: %v", synced, err))
+	}
+
+	respond(session, interaction, response)
+}
+
+func contentEdit(content string) discordgo.WebhookEdit {
+	return discordgo.WebhookEdit{
+		Content: &content,
+	}
+}